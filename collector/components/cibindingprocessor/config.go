@@ -0,0 +1,86 @@
+package cibindingprocessor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/configopaque"
+)
+
+const (
+	defaultTable = "cmdb_ci"
+	defaultField = "name"
+)
+
+// Config defines configuration for the CI binding processor.
+type Config struct {
+	// ClientConfig configures the connection to the CMDB Table API, with the endpoint
+	// set to the instance url. Ex: https://INSTANCE_NAME.service-now.com
+	// It can be left empty when only IdentifiersFile is used.
+	confighttp.ClientConfig `mapstructure:",squash"` // squash ensures fields are correctly decoded in embedded struct.
+
+	// Username is used to optionally specify the basic auth username
+	Username string `mapstructure:"username"`
+	// Password is used to optionally specify the basic auth password
+	Password configopaque.String `mapstructure:"password"`
+
+	// IdentifiersFile is an optional YAML file with static identifier rules, checked before the CMDB
+	IdentifiersFile string `mapstructure:"identifiers_file"`
+
+	// Identifiers are the resource attributes used to look up the CI, in order. The first one found
+	// on the resource and matching a CI is used.
+	Identifiers []IdentifierConfig `mapstructure:"identifiers"`
+
+	// Cache configures how long lookup results are kept
+	Cache CacheConfig `mapstructure:"cache"`
+}
+
+// IdentifierConfig maps a resource attribute to a field of a CMDB table.
+type IdentifierConfig struct {
+	// Attribute is the resource attribute key. Ex: host.name, k8s.pod.uid or cloud.resource_id
+	Attribute string `mapstructure:"attribute"`
+	// Table is the CMDB table the CI is looked up in, cmdb_ci by default
+	Table string `mapstructure:"table"`
+	// Field is the table field compared with the attribute value, name by default
+	Field string `mapstructure:"field"`
+}
+
+// CacheConfig defines how long CMDB lookups are cached.
+type CacheConfig struct {
+	// TTL is how long a CI found in the CMDB is cached
+	TTL time.Duration `mapstructure:"ttl"`
+	// NegativeTTL is how long a lookup that found no CI is cached
+	NegativeTTL time.Duration `mapstructure:"negative_ttl"`
+	// ErrorTTL is how long a failed lookup is cached, doubled on each consecutive failure up to the TTL.
+	// A CI found by a previous lookup stays bound meanwhile.
+	ErrorTTL time.Duration `mapstructure:"error_ttl"`
+	// MaxSize is the maximum number of lookups cached, unlimited when zero
+	MaxSize int `mapstructure:"max_size"`
+}
+
+var _ component.Config = (*Config)(nil)
+
+// Validate checks the processor configuration is valid
+func (cfg *Config) Validate() error {
+	if cfg.Endpoint == "" && cfg.IdentifiersFile == "" {
+		return errors.New("either endpoint or identifiers_file must be specified")
+	}
+	if len(cfg.Identifiers) == 0 {
+		return errors.New("at least one identifier must be specified")
+	}
+	for i, id := range cfg.Identifiers {
+		if id.Attribute == "" {
+			return fmt.Errorf("identifiers[%d]: attribute must be specified", i)
+		}
+	}
+	if cfg.Cache.TTL < 0 || cfg.Cache.NegativeTTL < 0 || cfg.Cache.ErrorTTL < 0 {
+		return errors.New("cache ttl values must not be negative")
+	}
+	if cfg.Cache.MaxSize < 0 {
+		return errors.New("cache max_size must not be negative")
+	}
+	return nil
+}
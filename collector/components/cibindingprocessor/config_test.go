@@ -0,0 +1,85 @@
+package cibindingprocessor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/confmap/confmaptest"
+
+	"github.com/lightstep/sn-collector/collector/cibindingprocessor/internal/metadata"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "config.yaml"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		id       component.ID
+		expected func() *Config
+		wantErr  string
+	}{
+		{
+			id: component.NewID(metadata.Type),
+			expected: func() *Config {
+				return createDefaultConfig().(*Config)
+			},
+			wantErr: "either endpoint or identifiers_file must be specified",
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "custom"),
+			expected: func() *Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Endpoint = "https://example.service-now.com"
+				cfg.Username = "admin"
+				cfg.Password = "secret"
+				cfg.IdentifiersFile = "testdata/identifiers.yaml"
+				cfg.Identifiers = []IdentifierConfig{
+					{Attribute: "k8s.pod.uid", Table: "cmdb_ci_kubernetes_pod", Field: "uid"},
+					{Attribute: "cloud.resource_id", Field: "object_id"},
+					{Attribute: "host.name"},
+				}
+				cfg.Cache = CacheConfig{TTL: 5 * time.Minute, NegativeTTL: 30 * time.Second, ErrorTTL: 5 * time.Second, MaxSize: 100}
+				return cfg
+			},
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "no_source"),
+			expected: func() *Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Identifiers = []IdentifierConfig{{Attribute: "host.name"}}
+				return cfg
+			},
+			wantErr: "either endpoint or identifiers_file must be specified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id.String(), func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig()
+			sub, err := cm.Sub(tt.id.String())
+			require.NoError(t, err)
+			require.NoError(t, component.UnmarshalConfig(sub, cfg))
+
+			assert.Equal(t, tt.expected(), cfg)
+			if tt.wantErr != "" {
+				assert.EqualError(t, component.ValidateConfig(cfg), tt.wantErr)
+			} else {
+				assert.NoError(t, component.ValidateConfig(cfg))
+			}
+		})
+	}
+}
+
+func TestValidateIdentifiers(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = "https://example.service-now.com"
+	cfg.Identifiers = nil
+	assert.EqualError(t, component.ValidateConfig(cfg), "at least one identifier must be specified")
+
+	cfg.Identifiers = []IdentifierConfig{{Attribute: "host.name"}, {Table: "cmdb_ci"}}
+	assert.EqualError(t, component.ValidateConfig(cfg), "identifiers[1]: attribute must be specified")
+}
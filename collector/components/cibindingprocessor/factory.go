@@ -0,0 +1,100 @@
+package cibindingprocessor
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/collector/processor/processorhelper"
+
+	"github.com/lightstep/sn-collector/collector/cibindingprocessor/internal/metadata"
+)
+
+var processorCapabilities = consumer.Capabilities{MutatesData: true}
+
+// NewFactory creates a new CI binding processor factory
+func NewFactory() processor.Factory {
+	return processor.NewFactory(
+		metadata.Type,
+		createDefaultConfig,
+		processor.WithMetrics(createMetricsProcessor, metadata.MetricsStability),
+		processor.WithLogs(createLogsProcessor, metadata.LogsStability),
+	)
+}
+
+// createDefaultConfig creates the default configuration, looking up CIs by host.name.
+func createDefaultConfig() component.Config {
+	clientConfig := confighttp.NewDefaultClientConfig()
+	clientConfig.Timeout = 10 * time.Second
+
+	return &Config{
+		ClientConfig: clientConfig,
+		Identifiers: []IdentifierConfig{
+			{Attribute: "host.name", Table: defaultTable, Field: defaultField},
+		},
+		Cache: CacheConfig{
+			TTL:         10 * time.Minute,
+			NegativeTTL: time.Minute,
+			ErrorTTL:    10 * time.Second,
+			MaxSize:     10000,
+		},
+	}
+}
+
+func createMetricsProcessor(
+	ctx context.Context,
+	set processor.CreateSettings,
+	cfg component.Config,
+	nextConsumer consumer.Metrics,
+) (processor.Metrics, error) {
+	p := newCIBindingProcessor(set.TelemetrySettings, withIdentifierDefaults(cfg.(*Config)))
+	return processorhelper.NewMetricsProcessor(
+		ctx,
+		set,
+		cfg,
+		nextConsumer,
+		p.processMetrics,
+		processorhelper.WithCapabilities(processorCapabilities),
+		processorhelper.WithStart(p.start),
+		processorhelper.WithShutdown(p.shutdown),
+	)
+}
+
+func createLogsProcessor(
+	ctx context.Context,
+	set processor.CreateSettings,
+	cfg component.Config,
+	nextConsumer consumer.Logs,
+) (processor.Logs, error) {
+	p := newCIBindingProcessor(set.TelemetrySettings, withIdentifierDefaults(cfg.(*Config)))
+	return processorhelper.NewLogsProcessor(
+		ctx,
+		set,
+		cfg,
+		nextConsumer,
+		p.processLogs,
+		processorhelper.WithCapabilities(processorCapabilities),
+		processorhelper.WithStart(p.start),
+		processorhelper.WithShutdown(p.shutdown),
+	)
+}
+
+// withIdentifierDefaults returns a copy of the config with the default table and field
+// set on the identifiers that don't specify them.
+func withIdentifierDefaults(cfg *Config) *Config {
+	c := *cfg
+	c.Identifiers = make([]IdentifierConfig, len(cfg.Identifiers))
+	for i, id := range cfg.Identifiers {
+		if id.Table == "" {
+			id.Table = defaultTable
+		}
+		if id.Field == "" {
+			id.Field = defaultField
+		}
+		c.Identifiers[i] = id
+	}
+	return &c
+}
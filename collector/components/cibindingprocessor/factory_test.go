@@ -0,0 +1,16 @@
+package cibindingprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestFactory(t *testing.T) {
+	f := NewFactory()
+	assert.EqualValues(t, "cibinding", f.Type().String())
+	cfg := f.CreateDefaultConfig()
+	assert.NotNil(t, cfg)
+	assert.NoError(t, componenttest.CheckConfigStruct(cfg))
+}
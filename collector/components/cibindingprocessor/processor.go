@@ -0,0 +1,113 @@
+package cibindingprocessor
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.uber.org/zap"
+)
+
+// ciSysIDAttribute is the resource attribute read by the servicenow exporter
+// to bind metrics and logs to a CI.
+const ciSysIDAttribute = "servicenow.ci.sys_id"
+
+type ciBindingProcessor struct {
+	config   *Config
+	settings component.TelemetrySettings
+	logger   *zap.Logger
+	resolver *ciResolver
+}
+
+func newCIBindingProcessor(settings component.TelemetrySettings, config *Config) *ciBindingProcessor {
+	return &ciBindingProcessor{
+		config:   config,
+		settings: settings,
+		logger:   settings.Logger,
+	}
+}
+
+// start loads the identifiers file and creates the Table API client.
+func (p *ciBindingProcessor) start(ctx context.Context, host component.Host) error {
+	resolver := &ciResolver{
+		endpoint: p.config.Endpoint,
+		username: p.config.Username,
+		password: string(p.config.Password),
+		timeout:  p.config.Timeout,
+		cacheCfg: p.config.Cache,
+		cache:    make(map[string]cacheEntry),
+		now:      time.Now,
+	}
+
+	if p.config.IdentifiersFile != "" {
+		rules, err := loadIdentifierRules(p.config.IdentifiersFile)
+		if err != nil {
+			return err
+		}
+		resolver.rules = rules
+	}
+	if resolver.timeout <= 0 {
+		resolver.timeout = defaultLookupTimeout
+	}
+
+	if p.config.Endpoint != "" {
+		httpClient, err := p.config.ToClient(ctx, host, p.settings)
+		if err != nil {
+			return err
+		}
+		resolver.httpClient = httpClient
+	}
+
+	p.resolver = resolver
+	return nil
+}
+
+func (p *ciBindingProcessor) shutdown(context.Context) error {
+	if p.resolver != nil && p.resolver.httpClient != nil {
+		p.resolver.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (p *ciBindingProcessor) processMetrics(ctx context.Context, md pmetric.Metrics) (pmetric.Metrics, error) {
+	for i := 0; i < md.ResourceMetrics().Len(); i++ {
+		p.bindResource(ctx, md.ResourceMetrics().At(i).Resource())
+	}
+	return md, nil
+}
+
+func (p *ciBindingProcessor) processLogs(ctx context.Context, ld plog.Logs) (plog.Logs, error) {
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		p.bindResource(ctx, ld.ResourceLogs().At(i).Resource())
+	}
+	return ld, nil
+}
+
+// bindResource sets servicenow.ci.sys_id using the first identifier that matches a CI.
+// Lookup failures are logged and the data is passed along unbound, unless the CI was
+// found by a previous lookup.
+func (p *ciBindingProcessor) bindResource(ctx context.Context, resource pcommon.Resource) {
+	attrs := resource.Attributes()
+	if _, ok := attrs.Get(ciSysIDAttribute); ok {
+		return
+	}
+
+	for _, id := range p.config.Identifiers {
+		value, ok := attrs.Get(id.Attribute)
+		if !ok || value.AsString() == "" {
+			continue
+		}
+
+		sysID, err := p.resolver.resolve(ctx, id, value.AsString())
+		if err != nil {
+			p.logger.Warn("Failed to look up CI", zap.String("attribute", id.Attribute), zap.String("value", value.AsString()), zap.Error(err))
+		}
+		if sysID != "" {
+			attrs.PutStr(ciSysIDAttribute, sysID)
+			return
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package cibindingprocessor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/processor/processortest"
+)
+
+// tableAPIMock answers Table API queries for hosts named web-02 and counts the requests received.
+func tableAPIMock(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "/api/now/table/cmdb_ci_server", r.URL.Path)
+		assert.Equal(t, "sys_id", r.URL.Query().Get("sysparm_fields"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("sysparm_query") == "name=web-02" {
+			fmt.Fprint(w, `{"result":[{"sys_id":"web02sysid"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"result":[]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestProcessor(t *testing.T, cfg *Config) *ciBindingProcessor {
+	p := newCIBindingProcessor(componenttest.NewNopTelemetrySettings(), withIdentifierDefaults(cfg))
+	require.NoError(t, p.start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, p.shutdown(context.Background())) })
+	return p
+}
+
+func resourceWith(attrs map[string]any) pcommon.Resource {
+	resource := pcommon.NewResource()
+	_ = resource.Attributes().FromRaw(attrs)
+	return resource
+}
+
+func sysIDOf(resource pcommon.Resource) string {
+	v, ok := resource.Attributes().Get(ciSysIDAttribute)
+	if !ok {
+		return ""
+	}
+	return v.AsString()
+}
+
+func TestBindResourceFromTableAPI(t *testing.T) {
+	var requests atomic.Int32
+	server := tableAPIMock(t, &requests)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	cfg.Identifiers = []IdentifierConfig{{Attribute: "host.name", Table: "cmdb_ci_server"}}
+	p := newTestProcessor(t, cfg)
+
+	found := resourceWith(map[string]any{"host.name": "web-02"})
+	p.bindResource(context.Background(), found)
+	assert.Equal(t, "web02sysid", sysIDOf(found))
+
+	missing := resourceWith(map[string]any{"host.name": "unknown"})
+	p.bindResource(context.Background(), missing)
+	assert.Equal(t, "", sysIDOf(missing))
+
+	// Both the found and the missing CIs are cached.
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "unknown"}))
+	assert.EqualValues(t, 2, requests.Load())
+}
+
+func TestBindResourceEscapesQuery(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("sysparm_query")
+		fmt.Fprint(w, `{"result":[]}`)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	p := newTestProcessor(t, cfg)
+
+	// The value cannot add an OR condition to the query.
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "unknown^ORname=web-02"}))
+	assert.Equal(t, "name=unknown^^ORname=web-02", query)
+}
+
+func TestBindResourceCacheExpires(t *testing.T) {
+	var requests atomic.Int32
+	server := tableAPIMock(t, &requests)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	cfg.Identifiers = []IdentifierConfig{{Attribute: "host.name", Table: "cmdb_ci_server"}}
+	p := newTestProcessor(t, cfg)
+
+	now := time.Now()
+	p.resolver.now = func() time.Time { return now }
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+
+	now = now.Add(cfg.Cache.TTL - time.Second)
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+	assert.EqualValues(t, 1, requests.Load())
+
+	now = now.Add(2 * time.Second)
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+	assert.EqualValues(t, 2, requests.Load())
+}
+
+func TestBindResourceFromIdentifiersFile(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.IdentifiersFile = filepath.Join("testdata", "identifiers.yaml")
+	cfg.Identifiers = []IdentifierConfig{{Attribute: "k8s.pod.uid"}, {Attribute: "host.name"}}
+	p := newTestProcessor(t, cfg)
+
+	pod := resourceWith(map[string]any{"k8s.pod.uid": "8b1f5bb8-5a5e-4a5b-9a4e-5b4e1b2c3d4e", "host.name": "web-01"})
+	p.bindResource(context.Background(), pod)
+	assert.Equal(t, "8d8c1b1e1b2c3d4e5f60718293a4b5c6", sysIDOf(pod))
+
+	// Falls back to the next identifier when the first one doesn't match.
+	host := resourceWith(map[string]any{"k8s.pod.uid": "other", "host.name": "web-01"})
+	p.bindResource(context.Background(), host)
+	assert.Equal(t, "3a5dd3dbc0a8ce0100655f1ec66ed42c", sysIDOf(host))
+
+	// An existing binding is kept.
+	bound := resourceWith(map[string]any{"host.name": "web-01", ciSysIDAttribute: "existing"})
+	p.bindResource(context.Background(), bound)
+	assert.Equal(t, "existing", sysIDOf(bound))
+}
+
+func TestBindResourceTableAPIError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	p := newTestProcessor(t, cfg)
+
+	now := time.Now()
+	p.resolver.now = func() time.Time { return now }
+	resource := resourceWith(map[string]any{"host.name": "web-02"})
+	p.bindResource(context.Background(), resource)
+	assert.Equal(t, "", sysIDOf(resource))
+
+	// The failure is cached for error_ttl, then for twice as long after the next failure.
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+	assert.EqualValues(t, 1, requests.Load())
+
+	now = now.Add(cfg.Cache.ErrorTTL)
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+	assert.EqualValues(t, 2, requests.Load())
+
+	now = now.Add(cfg.Cache.ErrorTTL)
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+	assert.EqualValues(t, 2, requests.Load())
+
+	now = now.Add(cfg.Cache.ErrorTTL)
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+	assert.EqualValues(t, 3, requests.Load())
+}
+
+func TestBindResourceConcurrentLookups(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+		fmt.Fprint(w, `{"result":[{"sys_id":"web02sysid"}]}`)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	p := newTestProcessor(t, cfg)
+
+	var wg sync.WaitGroup
+	resources := make([]pcommon.Resource, 10)
+	for i := range resources {
+		resources[i] = resourceWith(map[string]any{"host.name": "web-02"})
+		wg.Add(1)
+		go func(resource pcommon.Resource) {
+			defer wg.Done()
+			p.bindResource(context.Background(), resource)
+		}(resources[i])
+	}
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+	// Give the other lookups time to join the pending one.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, requests.Load())
+	for _, resource := range resources {
+		assert.Equal(t, "web02sysid", sysIDOf(resource))
+	}
+}
+
+func TestBindResourceCancelledCaller(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+		fmt.Fprint(w, `{"result":[{"sys_id":"web02sysid"}]}`)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	p := newTestProcessor(t, cfg)
+
+	// The caller starting the lookup gives up, the lookup goes on for the others.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := resourceWith(map[string]any{"host.name": "web-02"})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.bindResource(ctx, cancelled)
+	}()
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+	assert.Equal(t, "", sysIDOf(cancelled))
+
+	resource := resourceWith(map[string]any{"host.name": "web-02"})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	p.bindResource(context.Background(), resource)
+	assert.Equal(t, "web02sysid", sysIDOf(resource))
+	assert.EqualValues(t, 1, requests.Load())
+}
+
+func TestBindResourceKeepsSysIDOnError(t *testing.T) {
+	var failing atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"result":[{"sys_id":"web02sysid"}]}`)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	p := newTestProcessor(t, cfg)
+	now := time.Now()
+	p.resolver.now = func() time.Time { return now }
+	p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": "web-02"}))
+
+	// The CI stays bound while the CMDB is unavailable.
+	failing.Store(true)
+	for i := 0; i < 3; i++ {
+		now = now.Add(cfg.Cache.TTL)
+		resource := resourceWith(map[string]any{"host.name": "web-02"})
+		p.bindResource(context.Background(), resource)
+		assert.Equal(t, "web02sysid", sysIDOf(resource))
+	}
+}
+
+func TestBindResourceCacheMaxSize(t *testing.T) {
+	var requests atomic.Int32
+	server := tableAPIMock(t, &requests)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Endpoint = server.URL
+	cfg.Identifiers = []IdentifierConfig{{Attribute: "host.name", Table: "cmdb_ci_server"}}
+	cfg.Cache.MaxSize = 2
+	p := newTestProcessor(t, cfg)
+
+	for i := 0; i < 5; i++ {
+		p.bindResource(context.Background(), resourceWith(map[string]any{"host.name": fmt.Sprintf("host-%d", i)}))
+	}
+	assert.EqualValues(t, 5, requests.Load())
+	assert.Len(t, p.resolver.cache, 2)
+}
+
+func TestProcessMetricsAndLogs(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.IdentifiersFile = filepath.Join("testdata", "identifiers.yaml")
+
+	metricsSink := new(consumertest.MetricsSink)
+	mp, err := factory.CreateMetricsProcessor(context.Background(), processortest.NewNopCreateSettings(), cfg, metricsSink)
+	require.NoError(t, err)
+	require.NoError(t, mp.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, mp.Shutdown(context.Background())) }()
+
+	md := pmetric.NewMetrics()
+	md.ResourceMetrics().AppendEmpty().Resource().Attributes().PutStr("host.name", "web-01")
+	require.NoError(t, mp.ConsumeMetrics(context.Background(), md))
+	require.Len(t, metricsSink.AllMetrics(), 1)
+	assert.Equal(t, "3a5dd3dbc0a8ce0100655f1ec66ed42c", sysIDOf(metricsSink.AllMetrics()[0].ResourceMetrics().At(0).Resource()))
+
+	logsSink := new(consumertest.LogsSink)
+	lp, err := factory.CreateLogsProcessor(context.Background(), processortest.NewNopCreateSettings(), cfg, logsSink)
+	require.NoError(t, err)
+	require.NoError(t, lp.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, lp.Shutdown(context.Background())) }()
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().Resource().Attributes().PutStr("host.name", "web-01")
+	require.NoError(t, lp.ConsumeLogs(context.Background(), ld))
+	require.Len(t, logsSink.AllLogs(), 1)
+	assert.Equal(t, "3a5dd3dbc0a8ce0100655f1ec66ed42c", sysIDOf(logsSink.AllLogs()[0].ResourceLogs().At(0).Resource()))
+}
+
+func TestStartInvalidIdentifiersFile(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.IdentifiersFile = filepath.Join("testdata", "missing.yaml")
+	p := newCIBindingProcessor(componenttest.NewNopTelemetrySettings(), cfg)
+	assert.Error(t, p.start(context.Background(), componenttest.NewNopHost()))
+}
@@ -0,0 +1,224 @@
+package cibindingprocessor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gopkg.in/yaml.v3"
+)
+
+// identifierRule statically binds a resource attribute value to a CI.
+type identifierRule struct {
+	Attribute string `yaml:"attribute"`
+	Value     string `yaml:"value"`
+	SysID     string `yaml:"sys_id"`
+}
+
+// identifierRules is the format of the identifiers file:
+//
+//	identifiers:
+//	  - attribute: host.name
+//	    value: web-01
+//	    sys_id: 3a5dd3dbc0a8ce0100655f1ec66ed42c
+type identifierRules struct {
+	Identifiers []identifierRule `yaml:"identifiers"`
+}
+
+// loadIdentifierRules reads the identifiers file into a map of attribute -> value -> sys_id.
+func loadIdentifierRules(path string) (map[string]map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules identifierRules
+	if err := yaml.Unmarshal(content, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse identifiers file %q: %w", path, err)
+	}
+
+	byAttr := make(map[string]map[string]string)
+	for i, rule := range rules.Identifiers {
+		if rule.Attribute == "" || rule.Value == "" || rule.SysID == "" {
+			return nil, fmt.Errorf("identifiers file %q: entry %d must specify attribute, value and sys_id", path, i)
+		}
+		if byAttr[rule.Attribute] == nil {
+			byAttr[rule.Attribute] = make(map[string]string)
+		}
+		byAttr[rule.Attribute][rule.Value] = rule.SysID
+	}
+	return byAttr, nil
+}
+
+// tableResponse is the subset of the Table API response we use.
+// https://docs.servicenow.com/bundle/vancouver-api-reference/page/integrate/inbound-rest/concept/c_TableAPI.html
+type tableResponse struct {
+	Result []struct {
+		SysID string `json:"sys_id"`
+	} `json:"result"`
+}
+
+// defaultLookupTimeout bounds the CMDB lookups when the client has no timeout.
+const defaultLookupTimeout = 10 * time.Second
+
+type cacheEntry struct {
+	// sysID is kept from the last successful lookup while the following ones fail
+	sysID   string
+	expires time.Time
+	// failures is the number of consecutive failed lookups, zero for a lookup that succeeded
+	failures int
+}
+
+// ciResolver finds the sys_id of the CI matching an identifier, first in the
+// identifiers file and then in the CMDB, caching the CMDB results. Concurrent lookups
+// of the same value share a single request.
+type ciResolver struct {
+	rules      map[string]map[string]string
+	endpoint   string
+	username   string
+	password   string
+	httpClient *http.Client
+	timeout    time.Duration
+	cacheCfg   CacheConfig
+
+	lookups singleflight.Group
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+	now   func() time.Time
+}
+
+// resolve returns the sys_id of the CI, empty when none matches. When a lookup fails, the
+// sys_id of the last successful one, if any, is returned along with the error and kept
+// while the failure is cached.
+func (r *ciResolver) resolve(ctx context.Context, id IdentifierConfig, value string) (string, error) {
+	if sysID, ok := r.rules[id.Attribute][value]; ok {
+		return sysID, nil
+	}
+	if r.httpClient == nil {
+		return "", nil
+	}
+
+	key := id.Table + "|" + id.Field + "|" + value
+	r.mu.Lock()
+	entry, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok && r.now().Before(entry.expires) {
+		return entry.sysID, nil
+	}
+
+	// The lookup is shared by all the callers waiting for it, so it doesn't use the context
+	// of any of them: a cancelled caller would fail the others and cache the failure.
+	results := r.lookups.DoChan(key, func() (any, error) {
+		lookupCtx, cancel := context.WithTimeout(context.Background(), r.timeout)
+		defer cancel()
+		sysID, err := r.lookup(lookupCtx, id, value)
+		return r.store(key, sysID, err), err
+	})
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-results:
+		return res.Val.(string), res.Err
+	}
+}
+
+// store caches the result of a lookup and returns the sys_id to use. Failed lookups are cached
+// for error_ttl, doubled on each consecutive failure up to the ttl, so an unavailable CMDB is not
+// queried for every batch.
+func (r *ciResolver) store(key string, sysID string, err error) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	entry := cacheEntry{sysID: sysID}
+	switch {
+	case err != nil:
+		prev := r.cache[key]
+		entry.sysID = prev.sysID
+		entry.failures = prev.failures + 1
+		ttl := r.cacheCfg.ErrorTTL
+		for i := 1; i < entry.failures && ttl < r.cacheCfg.TTL; i++ {
+			ttl *= 2
+		}
+		entry.expires = now.Add(min(ttl, r.cacheCfg.TTL))
+	case sysID == "":
+		entry.expires = now.Add(r.cacheCfg.NegativeTTL)
+	default:
+		entry.expires = now.Add(r.cacheCfg.TTL)
+	}
+
+	if _, ok := r.cache[key]; !ok && r.cacheCfg.MaxSize > 0 && len(r.cache) >= r.cacheCfg.MaxSize {
+		r.evict(now)
+	}
+	r.cache[key] = entry
+	return entry.sysID
+}
+
+// evict removes the expired entries, or else an arbitrary one, to make room for a new entry.
+func (r *ciResolver) evict(now time.Time) {
+	for key, entry := range r.cache {
+		if !now.Before(entry.expires) {
+			delete(r.cache, key)
+		}
+	}
+	if len(r.cache) < r.cacheCfg.MaxSize {
+		return
+	}
+	for key := range r.cache {
+		delete(r.cache, key)
+		return
+	}
+}
+
+// escapeQueryValue escapes the ^ separating the conditions of an encoded query as ^^,
+// so attribute values cannot add conditions to the query.
+func escapeQueryValue(value string) string {
+	return strings.ReplaceAll(value, "^", "^^")
+}
+
+// lookup queries the Table API for the first CI whose field matches the value.
+func (r *ciResolver) lookup(ctx context.Context, id IdentifierConfig, value string) (string, error) {
+	query := url.Values{}
+	query.Set("sysparm_query", id.Field+"="+escapeQueryValue(value))
+	query.Set("sysparm_fields", "sys_id")
+	query.Set("sysparm_limit", "1")
+	reqURL := strings.TrimSuffix(r.endpoint, "/") + "/api/now/table/" + url.PathEscape(id.Table) + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	res, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		return "", fmt.Errorf("ServiceNow Table API returned non-200 status code: %d (%s)", res.StatusCode, string(body))
+	}
+
+	var tr tableResponse
+	if err := json.NewDecoder(res.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if len(tr.Result) == 0 {
+		return "", nil
+	}
+	return tr.Result[0].SysID, nil
+}
@@ -0,0 +1,187 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.uber.org/zap"
+)
+
+const defaultAccessTokensReloadInterval = 30 * time.Second
+
+// AccessTokensConfig restricts the reports accepted to the ones sent with a known access token.
+type AccessTokensConfig struct {
+	// Tokens are the access tokens accepted, along with their project.
+	Tokens []AccessToken `mapstructure:"tokens"`
+
+	// File lists more access tokens, one per line with an optional project after a space:
+	// "<token> [<project>]". Empty lines and lines starting with # are ignored.
+	// The file is reloaded when it changes.
+	File string `mapstructure:"file"`
+
+	// ReloadInterval is how often the file is checked for changes, every 30s by default.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+
+	// ProjectAttribute is the resource attribute set to the project of the access token,
+	// e.g. lightstep.project. Not set when empty.
+	ProjectAttribute string `mapstructure:"project_attribute"`
+}
+
+// AccessToken is an access token accepted by the receiver.
+type AccessToken struct {
+	Token   configopaque.String `mapstructure:"token"`
+	Project string              `mapstructure:"project"`
+}
+
+// Validate checks the access tokens configuration is valid
+func (cfg *AccessTokensConfig) Validate() error {
+	if len(cfg.Tokens) == 0 && cfg.File == "" {
+		return errors.New("access_tokens: tokens or file must be specified")
+	}
+	for _, t := range cfg.Tokens {
+		if t.Token == "" {
+			return errors.New("access_tokens: token must not be empty")
+		}
+	}
+	if cfg.ReloadInterval < 0 {
+		return errors.New("access_tokens: reload_interval must not be negative")
+	}
+	return nil
+}
+
+// tokenStore holds the accepted access tokens, with their project.
+type tokenStore struct {
+	config *AccessTokensConfig
+	logger *zap.Logger
+
+	mu     sync.RWMutex
+	tokens map[string]string
+
+	// modTime and size of the file when last loaded
+	modTime time.Time
+	size    int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newTokenStore loads the access tokens, failing when the file cannot be read.
+func newTokenStore(cfg *AccessTokensConfig, logger *zap.Logger) (*tokenStore, error) {
+	s := &tokenStore{
+		config: cfg,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if cfg.File != "" {
+		s.wg.Add(1)
+		go s.watch()
+	}
+	return s, nil
+}
+
+// lookup returns the project of an access token, and whether it is accepted.
+func (s *tokenStore) lookup(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	project, ok := s.tokens[token]
+	return project, ok
+}
+
+// watch reloads the file when it changes, keeping the previous tokens when it cannot be read.
+func (s *tokenStore) watch() {
+	defer s.wg.Done()
+	interval := s.config.ReloadInterval
+	if interval == 0 {
+		interval = defaultAccessTokensReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.load(); err != nil {
+				s.logger.Error("Failed to reload access tokens", zap.String("file", s.config.File), zap.Error(err))
+			}
+		}
+	}
+}
+
+// load reads the access tokens, skipping the file when it did not change. It is only called
+// by newTokenStore and then by watch, so the file state needs no locking.
+func (s *tokenStore) load() error {
+	var fileTokens map[string]string
+	if s.config.File != "" {
+		info, err := os.Stat(s.config.File)
+		if err != nil {
+			return err
+		}
+		if s.tokens != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+			return nil
+		}
+		if fileTokens, err = readTokensFile(s.config.File); err != nil {
+			return err
+		}
+		s.modTime, s.size = info.ModTime(), info.Size()
+		s.logger.Info("Loaded access tokens", zap.String("file", s.config.File), zap.Int("tokens", len(fileTokens)))
+	}
+
+	tokens := make(map[string]string, len(s.config.Tokens)+len(fileTokens))
+	for token, project := range fileTokens {
+		tokens[token] = project
+	}
+	// The inline tokens take precedence.
+	for _, t := range s.config.Tokens {
+		tokens[string(t.Token)] = t.Project
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = tokens
+	return nil
+}
+
+func readTokensFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tokens := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		switch len(fields) {
+		case 1:
+			tokens[fields[0]] = ""
+		case 2:
+			tokens[fields[0]] = fields[1]
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a token and an optional project", path, line)
+		}
+	}
+	return tokens, scanner.Err()
+}
+
+func (s *tokenStore) shutdown() {
+	close(s.done)
+	s.wg.Wait()
+}
@@ -0,0 +1,187 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configgrpc"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/confignet"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+func TestTokenStoreReloadsFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens.txt")
+	require.NoError(t, os.WriteFile(file, []byte("# tokens\nabc123 payments\n\ndef456\n"), 0o600))
+
+	store, err := newTokenStore(&AccessTokensConfig{
+		Tokens:         []AccessToken{{Token: "inline", Project: "search"}},
+		File:           file,
+		ReloadInterval: 10 * time.Millisecond,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	defer store.shutdown()
+
+	project, ok := store.lookup("abc123")
+	assert.True(t, ok)
+	assert.Equal(t, "payments", project)
+	_, ok = store.lookup("def456")
+	assert.True(t, ok)
+	project, ok = store.lookup("inline")
+	assert.True(t, ok)
+	assert.Equal(t, "search", project)
+	_, ok = store.lookup("ghi789")
+	assert.False(t, ok)
+
+	require.NoError(t, os.WriteFile(file, []byte("ghi789 checkout\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		_, ok := store.lookup("ghi789")
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	_, ok = store.lookup("abc123")
+	assert.False(t, ok)
+	_, ok = store.lookup("inline")
+	assert.True(t, ok)
+
+	// The previous tokens are kept when the file is invalid.
+	require.NoError(t, os.WriteFile(file, []byte("ghi789 checkout extra field\n"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	_, ok = store.lookup("ghi789")
+	assert.True(t, ok)
+}
+
+func TestTokenStoreMissingFile(t *testing.T) {
+	_, err := newTokenStore(&AccessTokensConfig{File: filepath.Join(t.TempDir(), "missing.txt")}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestGRPCAccessTokens(t *testing.T) {
+	addr := findAvailableAddress(t)
+	cfg := &Config{
+		Protocols: Protocols{
+			GRPC: &configgrpc.ServerConfig{
+				NetAddr: confignet.AddrConfig{
+					Endpoint:  addr,
+					Transport: confignet.TransportTypeTCP,
+				},
+			},
+		},
+		AccessTokens: &AccessTokensConfig{
+			Tokens:           []AccessToken{{Token: "abc123", Project: "payments"}},
+			ProjectAttribute: "lightstep.project",
+		},
+	}
+	sink := new(consumertest.TracesSink)
+
+	traceReceiver, err := newTracesReceiver(cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, traceReceiver.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, traceReceiver.Shutdown(context.Background())) })
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := collectorpb.NewCollectorServiceClient(conn)
+
+	resp, err := client.Report(context.Background(), createSimpleRequest())
+	require.NoError(t, err)
+	assert.Equal(t, []string{errUnknownAccessToken.Error()}, resp.GetErrors())
+
+	req := createSimpleRequest()
+	req.Auth = &collectorpb.Auth{AccessToken: "def456"}
+	resp, err = client.Report(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, []string{errUnknownAccessToken.Error()}, resp.GetErrors())
+	assert.Empty(t, sink.AllTraces())
+
+	req.Auth = &collectorpb.Auth{AccessToken: "abc123"}
+	resp, err = client.Report(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetErrors())
+
+	// The token can also be sent as metadata.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), AccessTokenHeader, "abc123")
+	resp, err = client.Report(ctx, createSimpleRequest())
+	require.NoError(t, err)
+	assert.Empty(t, resp.GetErrors())
+
+	traces := sink.AllTraces()
+	require.Len(t, traces, 2)
+	for _, td := range traces {
+		project, ok := td.ResourceSpans().At(0).Resource().Attributes().Get("lightstep.project")
+		require.True(t, ok)
+		assert.Equal(t, "payments", project.Str())
+	}
+}
+
+func TestHTTPAccessTokens(t *testing.T) {
+	addr := findAvailableAddress(t)
+	cfg := &Config{
+		Protocols: Protocols{
+			HTTP: &HTTPConfig{
+				ServerConfig: &confighttp.ServerConfig{
+					Endpoint: addr,
+				},
+			},
+		},
+		AccessTokens: &AccessTokensConfig{
+			Tokens: []AccessToken{{Token: "abc123"}},
+		},
+	}
+	sink := new(consumertest.TracesSink)
+
+	traceReceiver, err := newTracesReceiver(cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, traceReceiver.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, traceReceiver.Shutdown(context.Background())) })
+
+	client := http.Client{}
+	defer client.CloseIdleConnections()
+
+	httpReq, err := createHttpRequest(addr, createSimpleRequest())
+	require.NoError(t, err)
+	httpResp, err := client.Do(httpReq)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+	var resp collectorpb.ReportResponse
+	body, err := readBody(httpResp)
+	require.NoError(t, err)
+	require.NoError(t, proto.Unmarshal(body, &resp))
+	assert.Equal(t, []string{errUnknownAccessToken.Error()}, resp.GetErrors())
+
+	httpReq, err = createHttpRequest(addr, createSimpleRequest())
+	require.NoError(t, err)
+	httpReq.Header.Set(AccessTokenHeader, "abc123")
+	httpResp, err = client.Do(httpReq)
+	require.NoError(t, err)
+	httpResp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, httpResp.StatusCode)
+
+	traces := sink.AllTraces()
+	require.Len(t, traces, 1)
+	_, ok := traces[0].ResourceSpans().At(0).Resource().Attributes().Get("lightstep.project")
+	assert.False(t, ok)
+}
+
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
@@ -8,12 +8,14 @@ import (
 	"fmt"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configgrpc"
 	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/confmap"
 )
 
 const (
 	protocolsFieldName = "protocols"
+	protoGRPC          = "grpc"
 	protoHTTP          = "http"
 )
 
@@ -23,22 +25,48 @@ type HTTPConfig struct {
 
 // Protocols is the configuration for the supported protocols.
 type Protocols struct {
-	HTTP *HTTPConfig `mapstructure:"http"`
+	GRPC *configgrpc.ServerConfig `mapstructure:"grpc"`
+	HTTP *HTTPConfig              `mapstructure:"http"`
 }
 
 // Config defines configuration for the Lightstep receiver.
 type Config struct {
-	// Protocols is the configuration for the supported protocols, currently HTTP.
+	// Protocols is the configuration for the supported protocols, currently gRPC and HTTP.
 	Protocols `mapstructure:"protocols"`
+
+	// AccessTokens restricts the reports accepted to the ones with a known access token.
+	// All the reports are accepted when not set.
+	AccessTokens *AccessTokensConfig `mapstructure:"access_tokens"`
+
+	// Translation configures how the reports are translated to OTel data.
+	Translation TranslationConfig `mapstructure:"translation"`
+}
+
+// TranslationConfig defines how the reports are translated to OTel data.
+type TranslationConfig struct {
+	// TraceIDHighBits is the strategy synthesizing the high 64 bits of the trace ids, when
+	// they cannot be recovered from the otel.trace_id_high or traceparent span tags or
+	// baggage items of the report, or of the previous reports: zero (default) or hash.
+	TraceIDHighBits string `mapstructure:"trace_id_high_bits"`
+
+	// SemanticConventions renames the OpenTracing span tags, e.g. http.url, peer.hostname or
+	// db.statement, to the current OTel semantic conventions, e.g. url.full, server.address
+	// or db.query.text. The span tags are copied verbatim when disabled (default).
+	SemanticConventions bool `mapstructure:"semantic_conventions"`
 }
 
 var _ component.Config = (*Config)(nil)
 
 // Validate checks the receiver configuration is valid
 func (cfg *Config) Validate() error {
-	if cfg.HTTP == nil {
+	if cfg.GRPC == nil && cfg.HTTP == nil {
 		return errors.New("must specify at least one protocol when using the Lightstep receiver")
 	}
+	switch cfg.Translation.TraceIDHighBits {
+	case TraceIDHighBitsZero, TraceIDHighBitsHash:
+	default:
+		return fmt.Errorf("unsupported trace_id_high_bits %q, must be %s or %s", cfg.Translation.TraceIDHighBits, TraceIDHighBitsZero, TraceIDHighBitsHash)
+	}
 	return nil
 }
 
@@ -48,15 +76,23 @@ func (cfg *Config) Unmarshal(componentParser *confmap.Conf) error {
 		return fmt.Errorf("nil config for lightstepreceiver")
 	}
 
-	err := componentParser.Unmarshal(cfg)
+	protocols, err := componentParser.Sub(protocolsFieldName)
 	if err != nil {
 		return err
 	}
-	protocols, err := componentParser.Sub(protocolsFieldName)
+	// gRPC is opt-in, so its defaults are only applied when it is set.
+	if protocols.IsSet(protoGRPC) && cfg.GRPC == nil {
+		cfg.GRPC = createDefaultGRPCConfig()
+	}
+
+	err = componentParser.Unmarshal(cfg)
 	if err != nil {
 		return err
 	}
 
+	if !protocols.IsSet(protoGRPC) {
+		cfg.GRPC = nil
+	}
 	if !protocols.IsSet(protoHTTP) {
 		cfg.HTTP = nil
 	}
@@ -6,12 +6,15 @@ package lightstepreceiver
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configgrpc"
 	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/confignet"
 	"go.opentelemetry.io/collector/config/configtls"
 	"go.opentelemetry.io/collector/confmap"
 	"go.opentelemetry.io/collector/confmap/confmaptest"
@@ -26,6 +29,19 @@ func TestUnmarshalDefaultConfig(t *testing.T) {
 	assert.Equal(t, factory.CreateDefaultConfig(), cfg)
 }
 
+func TestUnmarshalConfigOnlyGRPC(t *testing.T) {
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "only_grpc.yaml"))
+	require.NoError(t, err)
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig()
+	assert.NoError(t, component.UnmarshalConfig(cm, cfg))
+
+	defaultOnlyGRPC := factory.CreateDefaultConfig().(*Config)
+	defaultOnlyGRPC.GRPC = createDefaultGRPCConfig()
+	defaultOnlyGRPC.HTTP = nil
+	assert.Equal(t, defaultOnlyGRPC, cfg)
+}
+
 func TestUnmarshalConfigOnlyHTTP(t *testing.T) {
 	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "only_http.yaml"))
 	require.NoError(t, err)
@@ -33,8 +49,7 @@ func TestUnmarshalConfigOnlyHTTP(t *testing.T) {
 	cfg := factory.CreateDefaultConfig()
 	assert.NoError(t, component.UnmarshalConfig(cm, cfg))
 
-	defaultOnlyHTTP := factory.CreateDefaultConfig().(*Config)
-	assert.Equal(t, defaultOnlyHTTP, cfg)
+	assert.Equal(t, factory.CreateDefaultConfig(), cfg)
 }
 
 func TestUnmarshalConfigOnlyHTTPNull(t *testing.T) {
@@ -44,8 +59,7 @@ func TestUnmarshalConfigOnlyHTTPNull(t *testing.T) {
 	cfg := factory.CreateDefaultConfig()
 	assert.NoError(t, component.UnmarshalConfig(cm, cfg))
 
-	defaultOnlyHTTP := factory.CreateDefaultConfig().(*Config)
-	assert.Equal(t, defaultOnlyHTTP, cfg)
+	assert.Equal(t, factory.CreateDefaultConfig(), cfg)
 }
 
 func TestUnmarshalConfigOnlyHTTPEmptyMap(t *testing.T) {
@@ -55,8 +69,7 @@ func TestUnmarshalConfigOnlyHTTPEmptyMap(t *testing.T) {
 	cfg := factory.CreateDefaultConfig()
 	assert.NoError(t, component.UnmarshalConfig(cm, cfg))
 
-	defaultOnlyHTTP := factory.CreateDefaultConfig().(*Config)
-	assert.Equal(t, defaultOnlyHTTP, cfg)
+	assert.Equal(t, factory.CreateDefaultConfig(), cfg)
 }
 
 func TestUnmarshalConfig(t *testing.T) {
@@ -68,6 +81,25 @@ func TestUnmarshalConfig(t *testing.T) {
 	assert.Equal(t,
 		&Config{
 			Protocols: Protocols{
+				GRPC: &configgrpc.ServerConfig{
+					NetAddr: confignet.AddrConfig{
+						Endpoint:  "0.0.0.0:8184",
+						Transport: confignet.TransportTypeTCP,
+					},
+					TLSSetting: &configtls.ServerConfig{
+						Config: configtls.Config{
+							CertFile: "test.crt",
+							KeyFile:  "test.key",
+						},
+					},
+					MaxRecvMsgSizeMiB:    32,
+					MaxConcurrentStreams: 16,
+					Keepalive: &configgrpc.KeepaliveServerConfig{
+						ServerParameters: &configgrpc.KeepaliveServerParameters{
+							MaxConnectionIdle: 11 * time.Second,
+						},
+					},
+				},
 				HTTP: &HTTPConfig{
 					ServerConfig: &confighttp.ServerConfig{
 						Endpoint: "0.0.0.0:443",
@@ -84,10 +116,51 @@ func TestUnmarshalConfig(t *testing.T) {
 					},
 				},
 			},
+			Translation: TranslationConfig{
+				TraceIDHighBits:     TraceIDHighBitsHash,
+				SemanticConventions: true,
+			},
 		}, cfg)
 
 }
 
+func TestUnmarshalConfigAccessTokens(t *testing.T) {
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "access_tokens.yaml"))
+	require.NoError(t, err)
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig()
+	assert.NoError(t, component.UnmarshalConfig(cm, cfg))
+	assert.NoError(t, component.ValidateConfig(cfg))
+	assert.Equal(t,
+		&AccessTokensConfig{
+			Tokens: []AccessToken{
+				{Token: "abc123", Project: "payments"},
+				{Token: "def456"},
+			},
+			File:             "/etc/lightstep/tokens.txt",
+			ReloadInterval:   10 * time.Second,
+			ProjectAttribute: "lightstep.project",
+		}, cfg.(*Config).AccessTokens)
+}
+
+func TestValidateAccessTokens(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.AccessTokens = &AccessTokensConfig{}
+	assert.EqualError(t, component.ValidateConfig(cfg), "access_tokens: tokens or file must be specified")
+
+	cfg.AccessTokens = &AccessTokensConfig{Tokens: []AccessToken{{Project: "payments"}}}
+	assert.EqualError(t, component.ValidateConfig(cfg), "access_tokens: token must not be empty")
+
+	cfg.AccessTokens = &AccessTokensConfig{File: "tokens.txt", ReloadInterval: -time.Second}
+	assert.EqualError(t, component.ValidateConfig(cfg), "access_tokens: reload_interval must not be negative")
+}
+
+func TestValidateTraceIDHighBits(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Translation.TraceIDHighBits = "random"
+	assert.EqualError(t, component.ValidateConfig(cfg), `unsupported trace_id_high_bits "random", must be zero or hash`)
+}
+
 func TestUnmarshalConfigTypoDefaultProtocol(t *testing.T) {
 	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "typo_default_proto_config.yaml"))
 	require.NoError(t, err)
@@ -5,9 +5,12 @@ package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-col
 
 import (
 	"context"
+	"sync"
 
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/configgrpc"
 	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/confignet"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/receiver"
 
@@ -18,7 +21,8 @@ import (
 
 const (
 	// TODO: Define a new port for us to use.
-	defaultBindEndpoint = "0.0.0.0:443"
+	defaultBindEndpoint     = "0.0.0.0:443"
+	defaultGRPCBindEndpoint = "0.0.0.0:8184"
 )
 
 // NewFactory creates a new Lightstep receiver factory
@@ -27,6 +31,8 @@ func NewFactory() receiver.Factory {
 		metadata.Type,
 		createDefaultConfig,
 		receiver.WithTraces(createTracesReceiver, metadata.TracesStability),
+		receiver.WithMetrics(createMetricsReceiver, metadata.MetricsStability),
+		receiver.WithLogs(createLogsReceiver, metadata.LogsStability),
 	)
 }
 
@@ -40,6 +46,20 @@ func createDefaultConfig() component.Config {
 				},
 			},
 		},
+		Translation: TranslationConfig{
+			TraceIDHighBits: TraceIDHighBitsZero,
+		},
+	}
+}
+
+// createDefaultGRPCConfig creates the default gRPC configuration, only used when
+// protocols.grpc is set as gRPC is opt-in.
+func createDefaultGRPCConfig() *configgrpc.ServerConfig {
+	return &configgrpc.ServerConfig{
+		NetAddr: confignet.AddrConfig{
+			Endpoint:  defaultGRPCBindEndpoint,
+			Transport: confignet.TransportTypeTCP,
+		},
 	}
 }
 
@@ -50,6 +70,99 @@ func createTracesReceiver(
 	cfg component.Config,
 	consumer consumer.Traces,
 ) (receiver.Traces, error) {
-	rCfg := cfg.(*Config)
-	return newReceiver(rCfg, consumer, set)
+	r, err := receivers.getOrAdd(cfg.(*Config), set)
+	if err != nil {
+		return nil, err
+	}
+	r.registerTracesConsumer(consumer)
+	return r, nil
+}
+
+// createMetricsReceiver creates a metrics receiver, converting the internal metrics
+// reported by the tracers, based on provided config.
+func createMetricsReceiver(
+	_ context.Context,
+	set receiver.CreateSettings,
+	cfg component.Config,
+	consumer consumer.Metrics,
+) (receiver.Metrics, error) {
+	r, err := receivers.getOrAdd(cfg.(*Config), set)
+	if err != nil {
+		return nil, err
+	}
+	r.registerMetricsConsumer(consumer)
+	return r, nil
+}
+
+// createLogsReceiver creates a logs receiver, converting the span logs, based on provided config.
+func createLogsReceiver(
+	_ context.Context,
+	set receiver.CreateSettings,
+	cfg component.Config,
+	consumer consumer.Logs,
+) (receiver.Logs, error) {
+	r, err := receivers.getOrAdd(cfg.(*Config), set)
+	if err != nil {
+		return nil, err
+	}
+	r.registerLogsConsumer(consumer)
+	return r, nil
+}
+
+// receivers holds the receivers shared by the pipelines using the same config, so a
+// single set of servers receives the reports and hands them over to all the pipelines.
+var receivers = &sharedReceivers{receivers: make(map[*Config]*sharedReceiver)}
+
+type sharedReceivers struct {
+	mu        sync.Mutex
+	receivers map[*Config]*sharedReceiver
+}
+
+func (s *sharedReceivers) getOrAdd(cfg *Config, set receiver.CreateSettings) (*sharedReceiver, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.receivers[cfg]; ok {
+		return r, nil
+	}
+
+	lr, err := newReceiver(cfg, set)
+	if err != nil {
+		return nil, err
+	}
+	r := &sharedReceiver{lightstepReceiver: lr}
+	r.remove = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.receivers, cfg)
+	}
+	s.receivers[cfg] = r
+	return r, nil
+}
+
+// sharedReceiver starts the receiver for the first pipeline started, and shuts it
+// down for the first pipeline shut down.
+type sharedReceiver struct {
+	*lightstepReceiver
+
+	startOnce    sync.Once
+	startErr     error
+	shutdownOnce sync.Once
+	remove       func()
+}
+
+// Start returns the error of the first start to all the pipelines.
+func (r *sharedReceiver) Start(ctx context.Context, host component.Host) error {
+	r.startOnce.Do(func() {
+		r.startErr = r.lightstepReceiver.Start(ctx, host)
+	})
+	return r.startErr
+}
+
+func (r *sharedReceiver) Shutdown(ctx context.Context) error {
+	var err error
+	r.shutdownOnce.Do(func() {
+		err = r.lightstepReceiver.Shutdown(ctx)
+		r.remove()
+	})
+	return err
 }
@@ -5,9 +5,11 @@ package lightstepreceiver
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer/consumertest"
@@ -39,3 +41,23 @@ func TestCreateReceiver(t *testing.T) {
 	assert.NoError(t, err, "receiver creation failed")
 	assert.NotNil(t, tReceiver, "receiver creation failed")
 }
+
+func TestSharedReceiverStartError(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:")
+	require.NoError(t, err)
+	defer l.Close()
+	cfg := createDefaultConfig().(*Config)
+	cfg.HTTP.ServerConfig.Endpoint = l.Addr().String()
+
+	factory := NewFactory()
+	tracesReceiver, err := factory.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	metricsReceiver, err := factory.CreateMetricsReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, tracesReceiver.Shutdown(context.Background())) })
+
+	// Every pipeline fails to start, not only the first one.
+	err = tracesReceiver.Start(context.Background(), componenttest.NewNopHost())
+	require.Error(t, err)
+	assert.Equal(t, err, metricsReceiver.Start(context.Background(), componenttest.NewNopHost()))
+}
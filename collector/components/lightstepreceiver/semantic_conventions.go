@@ -0,0 +1,140 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+// OpenTracing span tags with a special meaning.
+const (
+	tagSpanKind = "span.kind"
+	tagError    = "error"
+
+	logFieldErrorObject = "error.object"
+)
+
+// spanKinds maps the OpenTracing span.kind values to OTel span kinds.
+var spanKinds = map[string]ptrace.SpanKind{
+	"client":   ptrace.SpanKindClient,
+	"server":   ptrace.SpanKindServer,
+	"producer": ptrace.SpanKindProducer,
+	"consumer": ptrace.SpanKindConsumer,
+}
+
+// openTracingAttributes maps the OpenTracing span tags to the current OTel semantic conventions.
+// It is ordered so that peer.ipv4 wins over peer.ipv6 when both are set.
+var openTracingAttributes = []struct{ from, to string }{
+	{"http.url", "url.full"},
+	{"http.method", "http.request.method"},
+	{"http.status_code", "http.response.status_code"},
+	{"peer.hostname", "server.address"},
+	{"peer.port", "server.port"},
+	{"peer.ipv4", "network.peer.address"},
+	{"peer.ipv6", "network.peer.address"},
+	{"db.statement", "db.query.text"},
+	{"db.instance", "db.namespace"},
+	{"message_bus.destination", "messaging.destination.name"},
+}
+
+// serverSpanPeerAttributes overrides openTracingAttributes for the server spans, whose peer is the client.
+var serverSpanPeerAttributes = map[string]string{
+	"peer.hostname": "client.address",
+	"peer.port":     "client.port",
+}
+
+// setSpanKind sets the kind of the span from its span.kind tag, if any.
+func setSpanKind(span ptrace.Span, tags []*collectorpb.KeyValue) {
+	for _, kv := range tags {
+		if kv.GetKey() != tagSpanKind {
+			continue
+		}
+		if kind, ok := spanKinds[strings.ToLower(kv.GetStringValue())]; ok {
+			span.SetKind(kind)
+		}
+		return
+	}
+}
+
+// setSpanStatus sets the error status of the spans with an error=true (or error=1) tag, with
+// the message of their first error log.
+func setSpanStatus(span ptrace.Span, lspan *collectorpb.Span) {
+	if !hasErrorTag(lspan.GetTags()) {
+		return
+	}
+	span.Status().SetCode(ptrace.StatusCodeError)
+	for _, log := range lspan.GetLogs() {
+		if msg, ok := errorMessage(log); ok {
+			span.Status().SetMessage(msg)
+			return
+		}
+	}
+}
+
+func hasErrorTag(tags []*collectorpb.KeyValue) bool {
+	for _, kv := range tags {
+		if kv.GetKey() != tagError {
+			continue
+		}
+		switch x := kv.GetValue().(type) {
+		case *collectorpb.KeyValue_BoolValue:
+			return x.BoolValue
+		case *collectorpb.KeyValue_StringValue:
+			return strings.EqualFold(x.StringValue, "true")
+		case *collectorpb.KeyValue_IntValue:
+			// Some tracers send error=1.
+			return x.IntValue != 0
+		}
+	}
+	return false
+}
+
+// errorMessage returns the message of an error log, i.e. one with an error event or
+// an error.kind field, falling back to its error.object and error.kind fields.
+func errorMessage(log *collectorpb.Log) (string, bool) {
+	var message, object, kind string
+	for _, kv := range log.GetFields() {
+		switch kv.GetKey() {
+		case logFieldMessage:
+			message = kv.GetStringValue()
+		case logFieldErrorObject:
+			object = kv.GetStringValue()
+		case logFieldErrorKind:
+			kind = kv.GetStringValue()
+		}
+	}
+	if kind == "" && !isErrorEvent(log) {
+		return "", false
+	}
+	for _, msg := range []string{message, object, kind} {
+		if msg != "" {
+			return msg, true
+		}
+	}
+	return "", true
+}
+
+// translateOpenTracingAttributes renames the OpenTracing attributes of a span, unless
+// the attribute they are renamed to is already set.
+func translateOpenTracingAttributes(attrs pcommon.Map, kind ptrace.SpanKind) {
+	for _, attr := range openTracingAttributes {
+		v, ok := attrs.Get(attr.from)
+		if !ok {
+			continue
+		}
+		to := attr.to
+		if peer, ok := serverSpanPeerAttributes[attr.from]; ok && kind == ptrace.SpanKindServer {
+			to = peer
+		}
+		if _, exists := attrs.Get(to); !exists {
+			v.CopyTo(attrs.PutEmpty(to))
+		}
+		attrs.Remove(attr.from)
+	}
+}
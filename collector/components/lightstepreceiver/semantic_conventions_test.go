@@ -0,0 +1,125 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+func createTaggedRequest(tags []*collectorpb.KeyValue, logs ...*collectorpb.Log) *collectorpb.ReportRequest {
+	req := createSimpleRequest()
+	req.Spans[0].Tags = tags
+	req.Spans[0].Logs = logs
+	return req
+}
+
+func translateSpan(t *testing.T, req *collectorpb.ReportRequest, cfg TranslationConfig) ptrace.Span {
+	td, err := ToTraces(req, cfg)
+	require.NoError(t, err)
+	return td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+}
+
+func stringTag(key string, value string) *collectorpb.KeyValue {
+	return &collectorpb.KeyValue{Key: key, Value: &collectorpb.KeyValue_StringValue{StringValue: value}}
+}
+
+func TestSpanKind(t *testing.T) {
+	tests := []struct {
+		value string
+		want  ptrace.SpanKind
+	}{
+		{value: "client", want: ptrace.SpanKindClient},
+		{value: "server", want: ptrace.SpanKindServer},
+		{value: "producer", want: ptrace.SpanKindProducer},
+		{value: "Consumer", want: ptrace.SpanKindConsumer},
+		{value: "unknown", want: ptrace.SpanKindUnspecified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			span := translateSpan(t, createTaggedRequest([]*collectorpb.KeyValue{stringTag("span.kind", tt.value)}), TranslationConfig{})
+			assert.Equal(t, tt.want, span.Kind())
+		})
+	}
+
+	span := translateSpan(t, createSimpleRequest(), TranslationConfig{})
+	assert.Equal(t, ptrace.SpanKindUnspecified, span.Kind())
+}
+
+func TestSpanStatus(t *testing.T) {
+	errorTag := &collectorpb.KeyValue{Key: "error", Value: &collectorpb.KeyValue_BoolValue{BoolValue: true}}
+	infoLog := &collectorpb.Log{Fields: []*collectorpb.KeyValue{stringTag("message", "retrying")}}
+	errorLog := &collectorpb.Log{Fields: []*collectorpb.KeyValue{
+		stringTag("event", "error"),
+		stringTag("error.object", "java.io.IOException: connection refused"),
+	}}
+
+	span := translateSpan(t, createTaggedRequest([]*collectorpb.KeyValue{errorTag}, infoLog, errorLog), TranslationConfig{})
+	assert.Equal(t, ptrace.StatusCodeError, span.Status().Code())
+	assert.Equal(t, "java.io.IOException: connection refused", span.Status().Message())
+
+	span = translateSpan(t, createTaggedRequest([]*collectorpb.KeyValue{stringTag("error", "true")}), TranslationConfig{})
+	assert.Equal(t, ptrace.StatusCodeError, span.Status().Code())
+	assert.Empty(t, span.Status().Message())
+
+	intTag := &collectorpb.KeyValue{Key: "error", Value: &collectorpb.KeyValue_IntValue{IntValue: 1}}
+	span = translateSpan(t, createTaggedRequest([]*collectorpb.KeyValue{intTag}), TranslationConfig{})
+	assert.Equal(t, ptrace.StatusCodeError, span.Status().Code())
+
+	// Error logs alone do not set the status.
+	falseTag := &collectorpb.KeyValue{Key: "error", Value: &collectorpb.KeyValue_BoolValue{BoolValue: false}}
+	span = translateSpan(t, createTaggedRequest([]*collectorpb.KeyValue{falseTag}, errorLog), TranslationConfig{})
+	assert.Equal(t, ptrace.StatusCodeUnset, span.Status().Code())
+	zeroTag := &collectorpb.KeyValue{Key: "error", Value: &collectorpb.KeyValue_IntValue{IntValue: 0}}
+	span = translateSpan(t, createTaggedRequest([]*collectorpb.KeyValue{zeroTag}, errorLog), TranslationConfig{})
+	assert.Equal(t, ptrace.StatusCodeUnset, span.Status().Code())
+}
+
+func TestSemanticConventions(t *testing.T) {
+	tags := []*collectorpb.KeyValue{
+		stringTag("http.url", "https://example.com/cart"),
+		stringTag("peer.hostname", "cart.internal"),
+		stringTag("db.statement", "SELECT 1"),
+		stringTag("db.instance", "orders"),
+		stringTag("server.address", "10.0.0.1"),
+		{Key: "http.status_code", Value: &collectorpb.KeyValue_IntValue{IntValue: 200}},
+	}
+
+	span := translateSpan(t, createTaggedRequest(tags), TranslationConfig{SemanticConventions: true})
+	assert.Equal(t, map[string]any{
+		"url.full":                  "https://example.com/cart",
+		"db.query.text":             "SELECT 1",
+		"db.namespace":              "orders",
+		"http.response.status_code": int64(200),
+		// Attributes already following the semantic conventions are kept.
+		"server.address": "10.0.0.1",
+	}, span.Attributes().AsRaw())
+
+	// The peer of the server spans is the client.
+	peerTags := []*collectorpb.KeyValue{
+		stringTag("span.kind", "server"),
+		stringTag("peer.hostname", "checkout.internal"),
+		stringTag("peer.ipv6", "::1"),
+		stringTag("peer.ipv4", "10.0.0.2"),
+		{Key: "peer.port", Value: &collectorpb.KeyValue_IntValue{IntValue: 52100}},
+	}
+	span = translateSpan(t, createTaggedRequest(peerTags), TranslationConfig{SemanticConventions: true})
+	assert.Equal(t, map[string]any{
+		"span.kind":            "server",
+		"client.address":       "checkout.internal",
+		"client.port":          int64(52100),
+		"network.peer.address": "10.0.0.2",
+	}, span.Attributes().AsRaw())
+
+	// The tags are copied verbatim by default.
+	span = translateSpan(t, createTaggedRequest(tags), TranslationConfig{})
+	assert.Equal(t, 6, span.Attributes().Len())
+	_, ok := span.Attributes().Get("http.url")
+	assert.True(t, ok)
+}
@@ -0,0 +1,114 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	semconv "go.opentelemetry.io/collector/semconv/v1.18.0"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+// OpenTracing log fields with a special meaning.
+const (
+	logFieldMessage   = "message"
+	logFieldEvent     = "event"
+	logFieldErrorKind = "error.kind"
+
+	errorEventValue = "error"
+)
+
+// ToLogs converts the logs of the spans of a ReportRequest into OTel log records, carrying
+// the trace and span ids of their span. The `message` field, or the `event` field when
+// there is no message, becomes the body, and the remaining fields the attributes.
+// Logs with an `error.kind` field, or an `error` event, have the Error severity and the
+// other ones the Info severity.
+func ToLogs(req *collectorpb.ReportRequest, cfg TranslationConfig) (plog.Logs, error) {
+	return toLogs(req, cfg, nil)
+}
+
+// toLogs translates a report, recovering the high bits of its trace ids from traceIDCache as
+// well when not nil.
+func toLogs(req *collectorpb.ReportRequest, cfg TranslationConfig, traceIDCache *traceIDCache) (plog.Logs, error) {
+	ld := plog.NewLogs()
+	if req.Reporter == nil {
+		return ld, errors.New("Reporter in ReportRequest cannot be null.")
+	}
+	count := 0
+	for _, lspan := range req.GetSpans() {
+		count += len(lspan.GetLogs())
+	}
+	if count == 0 {
+		return ld, nil
+	}
+
+	reporter := req.GetReporter()
+	rls := ld.ResourceLogs().AppendEmpty()
+	resource := rls.Resource()
+	translateTagsToAttrs(reporter.GetTags(), resource.Attributes())
+	resource.Attributes().PutStr(semconv.AttributeServiceName, getServiceName(reporter.GetTags()))
+
+	sls := rls.ScopeLogs().AppendEmpty()
+	scope := sls.Scope()
+	scope.SetName(InstrumentationScopeName)
+	scope.SetVersion(InstrumentationScopeVersion)
+
+	records := sls.LogRecords()
+	records.EnsureCapacity(count)
+	tstampOffset, _ := time.ParseDuration(fmt.Sprintf("%dus", req.GetTimestampOffsetMicros()))
+	traceIDs := newTraceIDResolver(req, cfg.TraceIDHighBits, traceIDCache)
+
+	for _, lspan := range req.GetSpans() {
+		traceID := traceIDs.traceID(lspan.GetSpanContext().GetTraceId())
+		spanID := UInt64ToSpanID(lspan.GetSpanContext().GetSpanId())
+		for _, log := range lspan.GetLogs() {
+			record := records.AppendEmpty()
+			record.SetTraceID(traceID)
+			record.SetSpanID(spanID)
+			translateLogToRecord(log, record, tstampOffset)
+		}
+	}
+
+	return ld, nil
+}
+
+func translateLogToRecord(log *collectorpb.Log, record plog.LogRecord, offset time.Duration) {
+	tstamp := time.Unix(log.GetTimestamp().GetSeconds(), int64(log.GetTimestamp().GetNanos())).Add(offset)
+	record.SetTimestamp(pcommon.NewTimestampFromTime(tstamp))
+
+	attrs := record.Attributes()
+	translateTagsToAttrs(log.GetFields(), attrs)
+
+	for _, key := range []string{logFieldMessage, logFieldEvent} {
+		if v, ok := attrs.Get(key); ok {
+			v.CopyTo(record.Body())
+			attrs.Remove(key)
+			break
+		}
+	}
+
+	_, hasErrorKind := attrs.Get(logFieldErrorKind)
+	if hasErrorKind || isErrorEvent(log) {
+		record.SetSeverityNumber(plog.SeverityNumberError)
+		record.SetSeverityText(plog.SeverityNumberError.String())
+	} else {
+		record.SetSeverityNumber(plog.SeverityNumberInfo)
+		record.SetSeverityText(plog.SeverityNumberInfo.String())
+	}
+}
+
+// isErrorEvent checks for the `event: error` field OpenTracing uses for error logs.
+func isErrorEvent(log *collectorpb.Log) bool {
+	for _, kv := range log.GetFields() {
+		if kv.GetKey() == logFieldEvent && kv.GetStringValue() == errorEventValue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+func TestTranslateLogsReporterNil(t *testing.T) {
+	logs, err := ToLogs(&collectorpb.ReportRequest{}, TranslationConfig{})
+	assert.Equal(t, errors.New("Reporter in ReportRequest cannot be null."), err)
+	assert.Equal(t, plog.NewLogs(), logs)
+}
+
+func TestTranslateSpansWithoutLogs(t *testing.T) {
+	logs, err := ToLogs(createSimpleRequest(), TranslationConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, plog.NewLogs(), logs)
+}
+
+func TestTranslateSpanLogs(t *testing.T) {
+	start_t := time.Now()
+	req := &collectorpb.ReportRequest{
+		Reporter: &collectorpb.Reporter{
+			Tags: []*collectorpb.KeyValue{
+				{
+					Key: "lightstep.component_name",
+					Value: &collectorpb.KeyValue_StringValue{
+						StringValue: "GatewayService",
+					},
+				},
+			},
+		},
+		Spans: []*collectorpb.Span{
+			{
+				SpanContext: &collectorpb.SpanContext{
+					TraceId: TraceID1,
+					SpanId:  SpanID1,
+				},
+				OperationName:  "span1",
+				StartTimestamp: timestamppb.New(start_t),
+				Logs: []*collectorpb.Log{
+					{
+						Timestamp: timestamppb.New(start_t),
+						Fields: []*collectorpb.KeyValue{
+							{
+								Key: "event",
+								Value: &collectorpb.KeyValue_StringValue{
+									StringValue: "cache miss",
+								},
+							},
+							{
+								Key: "key",
+								Value: &collectorpb.KeyValue_StringValue{
+									StringValue: "user:42",
+								},
+							},
+						},
+					},
+					{
+						Timestamp: timestamppb.New(start_t),
+						Fields: []*collectorpb.KeyValue{
+							{
+								Key: "event",
+								Value: &collectorpb.KeyValue_StringValue{
+									StringValue: "error",
+								},
+							},
+							{
+								Key: "message",
+								Value: &collectorpb.KeyValue_StringValue{
+									StringValue: "connection refused",
+								},
+							},
+							{
+								Key: "error.kind",
+								Value: &collectorpb.KeyValue_StringValue{
+									StringValue: "IOException",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	logs, err := ToLogs(req, TranslationConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, logs, func() plog.Logs {
+		ld := plog.NewLogs()
+		rl := ld.ResourceLogs().AppendEmpty()
+
+		rattrs := rl.Resource().Attributes()
+		rattrs.PutStr("lightstep.component_name", "GatewayService")
+		rattrs.PutStr("service.name", "GatewayService") // derived
+
+		sl := rl.ScopeLogs().AppendEmpty()
+		scope := sl.Scope()
+		scope.SetName("lightstep-receiver")
+		scope.SetVersion("0.0.1")
+
+		log1 := sl.LogRecords().AppendEmpty()
+		log1.SetTimestamp(pcommon.NewTimestampFromTime(start_t))
+		log1.SetTraceID(UInt64ToTraceID(0, TraceID1))
+		log1.SetSpanID(UInt64ToSpanID(SpanID1))
+		log1.SetSeverityNumber(plog.SeverityNumberInfo)
+		log1.SetSeverityText("Info")
+		log1.Body().SetStr("cache miss")
+		log1.Attributes().PutStr("key", "user:42")
+
+		log2 := sl.LogRecords().AppendEmpty()
+		log2.SetTimestamp(pcommon.NewTimestampFromTime(start_t))
+		log2.SetTraceID(UInt64ToTraceID(0, TraceID1))
+		log2.SetSpanID(UInt64ToSpanID(SpanID1))
+		log2.SetSeverityNumber(plog.SeverityNumberError)
+		log2.SetSeverityText("Error")
+		log2.Body().SetStr("connection refused")
+		log2.Attributes().PutStr("event", "error")
+		log2.Attributes().PutStr("error.kind", "IOException")
+
+		return ld
+	}())
+}
@@ -0,0 +1,82 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	semconv "go.opentelemetry.io/collector/semconv/v1.18.0"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+// ToMetrics converts the InternalMetrics of a ReportRequest, which the tracers report
+// about themselves (e.g. dropped spans), into OTel metrics. Counts become delta monotonic
+// sums and gauges become gauges, each one with a single data point covering the period
+// from the start timestamp through the duration.
+func ToMetrics(req *collectorpb.ReportRequest) (pmetric.Metrics, error) {
+	md := pmetric.NewMetrics()
+	if req.Reporter == nil {
+		return md, errors.New("Reporter in ReportRequest cannot be null.")
+	}
+	internal := req.GetInternalMetrics()
+	if len(internal.GetCounts()) == 0 && len(internal.GetGauges()) == 0 {
+		return md, nil
+	}
+
+	reporter := req.GetReporter()
+	rms := md.ResourceMetrics().AppendEmpty()
+	resource := rms.Resource()
+	translateTagsToAttrs(reporter.GetTags(), resource.Attributes())
+	resource.Attributes().PutStr(semconv.AttributeServiceName, getServiceName(reporter.GetTags()))
+
+	sms := rms.ScopeMetrics().AppendEmpty()
+	scope := sms.Scope()
+	scope.SetName(InstrumentationScopeName)
+	scope.SetVersion(InstrumentationScopeVersion)
+
+	tstampOffset, _ := time.ParseDuration(fmt.Sprintf("%dus", req.GetTimestampOffsetMicros()))
+	ts := internal.GetStartTimestamp()
+	startt := time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).Add(tstampOffset)
+	duration, _ := time.ParseDuration(fmt.Sprintf("%dus", int64(internal.GetDurationMicros())))
+	start := pcommon.NewTimestampFromTime(startt)
+	end := pcommon.NewTimestampFromTime(startt.Add(duration))
+
+	metrics := sms.Metrics()
+	metrics.EnsureCapacity(len(internal.GetCounts()) + len(internal.GetGauges()))
+	for _, sample := range internal.GetCounts() {
+		metric := metrics.AppendEmpty()
+		metric.SetName(sample.GetName())
+		sum := metric.SetEmptySum()
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		sum.SetIsMonotonic(true)
+		dp := sum.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(start)
+		dp.SetTimestamp(end)
+		translateSampleValue(sample, dp)
+	}
+	for _, sample := range internal.GetGauges() {
+		metric := metrics.AppendEmpty()
+		metric.SetName(sample.GetName())
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(start)
+		dp.SetTimestamp(end)
+		translateSampleValue(sample, dp)
+	}
+
+	return md, nil
+}
+
+func translateSampleValue(sample *collectorpb.MetricsSample, dp pmetric.NumberDataPoint) {
+	switch x := sample.GetValue().(type) {
+	case *collectorpb.MetricsSample_IntValue:
+		dp.SetIntValue(x.IntValue)
+	case *collectorpb.MetricsSample_DoubleValue:
+		dp.SetDoubleValue(x.DoubleValue)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+func TestTranslateMetricsReporterNil(t *testing.T) {
+	metrics, err := ToMetrics(&collectorpb.ReportRequest{})
+	assert.Equal(t, errors.New("Reporter in ReportRequest cannot be null."), err)
+	assert.Equal(t, pmetric.NewMetrics(), metrics)
+}
+
+func TestTranslateEmptyInternalMetrics(t *testing.T) {
+	req := createSimpleRequest()
+	metrics, err := ToMetrics(req)
+	assert.NoError(t, err)
+	assert.Equal(t, pmetric.NewMetrics(), metrics)
+
+	req.InternalMetrics = &collectorpb.InternalMetrics{StartTimestamp: timestamppb.Now()}
+	metrics, err = ToMetrics(req)
+	assert.NoError(t, err)
+	assert.Equal(t, pmetric.NewMetrics(), metrics)
+}
+
+func TestTranslateInternalMetrics(t *testing.T) {
+	duration, _ := time.ParseDuration(fmt.Sprintf("%dus", 30000000))
+	offset, _ := time.ParseDuration(fmt.Sprintf("%dus", 13571113))
+	start_t := time.Now()
+	req := &collectorpb.ReportRequest{
+		Reporter: &collectorpb.Reporter{
+			Tags: []*collectorpb.KeyValue{
+				{
+					Key: "lightstep.component_name",
+					Value: &collectorpb.KeyValue_StringValue{
+						StringValue: "GatewayService",
+					},
+				},
+				{
+					Key: "lightstep.tracer_platform",
+					Value: &collectorpb.KeyValue_StringValue{
+						StringValue: "java",
+					},
+				},
+			},
+		},
+		TimestampOffsetMicros: offset.Microseconds(),
+		InternalMetrics: &collectorpb.InternalMetrics{
+			StartTimestamp: timestamppb.New(start_t),
+			DurationMicros: uint64(duration.Microseconds()),
+			Counts: []*collectorpb.MetricsSample{
+				{
+					Name:  "spans.dropped",
+					Value: &collectorpb.MetricsSample_IntValue{IntValue: 12},
+				},
+			},
+			Gauges: []*collectorpb.MetricsSample{
+				{
+					Name:  "buffer.fill",
+					Value: &collectorpb.MetricsSample_DoubleValue{DoubleValue: 0.75},
+				},
+			},
+		},
+	}
+	metrics, err := ToMetrics(req)
+	assert.NoError(t, err)
+	assert.Equal(t, metrics, func() pmetric.Metrics {
+		md := pmetric.NewMetrics()
+		rm := md.ResourceMetrics().AppendEmpty()
+
+		rattrs := rm.Resource().Attributes()
+		rattrs.PutStr("lightstep.component_name", "GatewayService")
+		rattrs.PutStr("lightstep.tracer_platform", "java")
+		rattrs.PutStr("service.name", "GatewayService") // derived
+
+		sm := rm.ScopeMetrics().AppendEmpty()
+		scope := sm.Scope()
+		scope.SetName("lightstep-receiver")
+		scope.SetVersion("0.0.1")
+
+		start := pcommon.NewTimestampFromTime(start_t.Add(offset))
+		end := pcommon.NewTimestampFromTime(start_t.Add(offset).Add(duration))
+
+		m1 := sm.Metrics().AppendEmpty()
+		m1.SetName("spans.dropped")
+		sum := m1.SetEmptySum()
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		sum.SetIsMonotonic(true)
+		dp1 := sum.DataPoints().AppendEmpty()
+		dp1.SetStartTimestamp(start)
+		dp1.SetTimestamp(end)
+		dp1.SetIntValue(12)
+
+		m2 := sm.Metrics().AppendEmpty()
+		m2.SetName("buffer.fill")
+		dp2 := m2.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp2.SetStartTimestamp(start)
+		dp2.SetTimestamp(end)
+		dp2.SetDoubleValue(0.75)
+
+		return md
+	}())
+}
@@ -20,7 +20,13 @@ const (
 	InstrumentationScopeVersion = "0.0.1" // TODO: Use the actual internal version?
 )
 
-func ToTraces(req *collectorpb.ReportRequest) (ptrace.Traces, error) {
+func ToTraces(req *collectorpb.ReportRequest, cfg TranslationConfig) (ptrace.Traces, error) {
+	return toTraces(req, cfg, nil)
+}
+
+// toTraces translates a report, recovering the high bits of its trace ids from traceIDCache as well
+// when not nil.
+func toTraces(req *collectorpb.ReportRequest, cfg TranslationConfig, traceIDCache *traceIDCache) (ptrace.Traces, error) {
 	td := ptrace.NewTraces()
 	if req.Reporter == nil {
 		return td, errors.New("Reporter in ReportRequest cannot be null.")
@@ -44,18 +50,24 @@ func ToTraces(req *collectorpb.ReportRequest) (ptrace.Traces, error) {
 	spans := sss.Spans()
 	spans.EnsureCapacity(len(req.GetSpans()))
 	tstampOffset, _ := time.ParseDuration(fmt.Sprintf("%dus", req.GetTimestampOffsetMicros()))
+	traceIDs := newTraceIDResolver(req, cfg.TraceIDHighBits, traceIDCache)
 
 	for _, lspan := range req.GetSpans() {
 		span := spans.AppendEmpty()
-		translateToSpan(lspan, span, tstampOffset)
+		translateToSpan(lspan, span, tstampOffset, traceIDs, cfg)
 	}
 
 	return td, nil
 }
 
-func translateToSpan(lspan *collectorpb.Span, span ptrace.Span, offset time.Duration) {
+func translateToSpan(lspan *collectorpb.Span, span ptrace.Span, offset time.Duration, traceIDs *traceIDResolver, cfg TranslationConfig) {
 	span.SetName(lspan.GetOperationName())
 	translateTagsToAttrs(lspan.GetTags(), span.Attributes())
+	setSpanKind(span, lspan.GetTags())
+	setSpanStatus(span, lspan)
+	if cfg.SemanticConventions {
+		translateOpenTracingAttributes(span.Attributes(), span.Kind())
+	}
 
 	ts := lspan.GetStartTimestamp()
 	startt := time.Unix(ts.GetSeconds(), int64(ts.GetNanos()))
@@ -65,9 +77,9 @@ func translateToSpan(lspan *collectorpb.Span, span ptrace.Span, offset time.Dura
 	span.SetEndTimestamp(pcommon.NewTimestampFromTime(startt.Add(duration).Add(offset)))
 
 	// Legacy tracers use TraceIds of only 64 bit length.
-	span.SetTraceID(UInt64ToTraceID(0, lspan.GetSpanContext().GetTraceId()))
+	span.SetTraceID(traceIDs.traceID(lspan.GetSpanContext().GetTraceId()))
 	span.SetSpanID(UInt64ToSpanID(lspan.GetSpanContext().GetSpanId()))
-	setSpanParents(span, lspan.GetReferences())
+	setSpanParents(span, lspan.GetReferences(), traceIDs)
 
 	translateLogsToEvents(span, lspan.GetLogs(), offset)
 }
@@ -103,7 +115,7 @@ func getServiceName(tags []*collectorpb.KeyValue) string {
 	return "unknown_service"
 }
 
-func setSpanParents(span ptrace.Span, refs []*collectorpb.Reference) {
+func setSpanParents(span ptrace.Span, refs []*collectorpb.Reference, traceIDs *traceIDResolver) {
 	if len(refs) == 0 {
 		return
 	}
@@ -122,7 +134,7 @@ func setSpanParents(span ptrace.Span, refs []*collectorpb.Reference) {
 		} else {
 			link := links.AppendEmpty()
 			link.SetSpanID(UInt64ToSpanID(ref.GetSpanContext().GetSpanId()))
-			link.SetTraceID(UInt64ToTraceID(0, ref.GetSpanContext().GetTraceId()))
+			link.SetTraceID(traceIDs.traceID(ref.GetSpanContext().GetTraceId()))
 		}
 	}
 }
@@ -33,7 +33,7 @@ func TestTranslateAllMembersNil(t *testing.T) {
 		TimestampOffsetMicros: 0,
 		InternalMetrics:       nil,
 	}
-	traces, err := ToTraces(req)
+	traces, err := ToTraces(req, TranslationConfig{})
 	assert.Equal(t, errors.New("Reporter in ReportRequest cannot be null."), err)
 	assert.Equal(t, ptrace.NewTraces(), traces)
 }
@@ -52,7 +52,7 @@ func TestTranslateEmptySpans(t *testing.T) {
 		},
 		Spans: []*collectorpb.Span{},
 	}
-	traces, err := ToTraces(req)
+	traces, err := ToTraces(req, TranslationConfig{})
 	assert.NoError(t, err)
 	assert.Equal(t, traces, ptrace.NewTraces())
 }
@@ -66,7 +66,7 @@ func TestTranslatNoComponentName(t *testing.T) {
 			},
 		},
 	}
-	traces, err := ToTraces(req)
+	traces, err := ToTraces(req, TranslationConfig{})
 	assert.NoError(t, err)
 	assert.Equal(t, traces, func() ptrace.Traces {
 		td := ptrace.NewTraces()
@@ -206,7 +206,7 @@ func TestAttributes(t *testing.T) {
 			},
 		},
 	}
-	traces, err := ToTraces(req)
+	traces, err := ToTraces(req, TranslationConfig{})
 	assert.NoError(t, err)
 	assert.Equal(t, traces, func() ptrace.Traces {
 		td := ptrace.NewTraces()
@@ -315,7 +315,7 @@ func TestReferences(t *testing.T) {
 			},
 		},
 	}
-	traces, err := ToTraces(req)
+	traces, err := ToTraces(req, TranslationConfig{})
 	assert.NoError(t, err)
 	assert.Equal(t, traces, func() ptrace.Traces {
 		td := ptrace.NewTraces()
@@ -412,7 +412,7 @@ func TestTimestampOffset(t *testing.T) {
 			},
 		},
 	}
-	traces, err := ToTraces(req)
+	traces, err := ToTraces(req, TranslationConfig{})
 	assert.NoError(t, err)
 	assert.Equal(t, traces, func() ptrace.Traces {
 		td := ptrace.NewTraces()
@@ -544,7 +544,7 @@ func TestTranslateFullSimple(t *testing.T) {
 			},
 		},
 	}
-	traces, err := ToTraces(req)
+	traces, err := ToTraces(req, TranslationConfig{})
 	assert.NoError(t, err)
 	assert.Equal(t, traces, func() ptrace.Traces {
 		td := ptrace.NewTraces()
@@ -0,0 +1,219 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+// Strategies synthesizing the high 64 bits of the trace ids that cannot be recovered.
+const (
+	// TraceIDHighBitsZero pads the trace ids with zeros, as the OTel propagators do
+	// when they extract 64 bits trace ids (e.g. B3 or OpenTracing headers).
+	TraceIDHighBitsZero = "zero"
+	// TraceIDHighBitsHash derives the high bits from a hash of the low bits, so trace
+	// ids are still consistent while unlikely to collide with zero padded ones.
+	TraceIDHighBitsHash = "hash"
+)
+
+// Tags, or baggage items, holding the high bits of the trace id.
+const (
+	traceIDHighKey = "otel.trace_id_high"
+	traceparentKey = "traceparent"
+)
+
+// Bounds of the high bits kept across reports, the spans of a trace being sent in several
+// reports, by several tracers, within a few minutes.
+const (
+	traceIDCacheSize = 100_000
+	traceIDCacheTTL  = 10 * time.Minute
+)
+
+// traceIDCache keeps the high bits recovered from the reports by low bits, so they also apply
+// to the spans of the same traces sent in later reports. The least recently used ones are
+// evicted once it is full, and the ones not used for the ttl are expired.
+type traceIDCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[uint64]*list.Element
+	// lru holds the *traceIDEntry, the most recently used first
+	lru *list.List
+	now func() time.Time
+}
+
+type traceIDEntry struct {
+	low      uint64
+	high     uint64
+	lastUsed time.Time
+}
+
+func newTraceIDCache(size int, ttl time.Duration) *traceIDCache {
+	return &traceIDCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[uint64]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the high bits of the low bits, if they are known and not expired.
+func (c *traceIDCache) get(low uint64) (uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[low]
+	if !ok {
+		return 0, false
+	}
+	entry := elem.Value.(*traceIDEntry)
+	now := c.now()
+	if now.Sub(entry.lastUsed) >= c.ttl {
+		c.lru.Remove(elem)
+		delete(c.entries, low)
+		return 0, false
+	}
+	entry.lastUsed = now
+	c.lru.MoveToFront(elem)
+	return entry.high, true
+}
+
+// put records the high bits of the low bits, evicting the least recently used ones when full.
+func (c *traceIDCache) put(low uint64, high uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if elem, ok := c.entries[low]; ok {
+		entry := elem.Value.(*traceIDEntry)
+		entry.high, entry.lastUsed = high, now
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[low] = c.lru.PushFront(&traceIDEntry{low: low, high: high, lastUsed: now})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*traceIDEntry).low)
+	}
+}
+
+// traceIDResolver extends the 64 bits trace ids of the legacy tracers to 128 bits.
+type traceIDResolver struct {
+	strategy string
+	// high holds the high bits recovered from the spans of the report, by low bits
+	high map[uint64]uint64
+	// cache holds the high bits recovered from the previous reports, nil when not shared
+	cache *traceIDCache
+}
+
+// newTraceIDResolver recovers the high bits of the trace ids from the span tags and baggage,
+// so they also apply to the spans of the same traces, and their references, not carrying them.
+// The high bits recovered are added to the cache, when not nil, for the next reports.
+func newTraceIDResolver(req *collectorpb.ReportRequest, strategy string, cache *traceIDCache) *traceIDResolver {
+	r := &traceIDResolver{
+		strategy: strategy,
+		high:     make(map[uint64]uint64),
+		cache:    cache,
+	}
+	for _, lspan := range req.GetSpans() {
+		low := lspan.GetSpanContext().GetTraceId()
+		if _, ok := r.high[low]; ok {
+			continue
+		}
+		if high, ok := recoverHighBits(lspan); ok {
+			r.high[low] = high
+			if cache != nil {
+				cache.put(low, high)
+			}
+		}
+	}
+	return r
+}
+
+// traceID returns the 128 bits trace id for the low bits sent by a tracer. The high bits are
+// only synthesized when they were neither recovered from this report nor from a previous one,
+// the spans of a trace received before the high bits were keeping the synthesized ones.
+func (r *traceIDResolver) traceID(low uint64) pcommon.TraceID {
+	high, ok := r.high[low]
+	if !ok && r.cache != nil {
+		if high, ok = r.cache.get(low); ok {
+			r.high[low] = high
+		}
+	}
+	if !ok {
+		high = synthesizeHighBits(r.strategy, low)
+	}
+	return UInt64ToTraceID(high, low)
+}
+
+func synthesizeHighBits(strategy string, low uint64) uint64 {
+	if strategy != TraceIDHighBitsHash || low == 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], low)
+	_, _ = h.Write(b[:])
+	return h.Sum64()
+}
+
+// recoverHighBits looks for the high bits in the span tags, and then in the baggage.
+func recoverHighBits(lspan *collectorpb.Span) (uint64, bool) {
+	low := lspan.GetSpanContext().GetTraceId()
+	for _, kv := range lspan.GetTags() {
+		switch kv.GetKey() {
+		case traceIDHighKey:
+			if v, ok := kv.GetValue().(*collectorpb.KeyValue_IntValue); ok {
+				return uint64(v.IntValue), true
+			}
+			if high, ok := parseHighBits(kv.GetStringValue()); ok {
+				return high, true
+			}
+		case traceparentKey:
+			if high, ok := parseTraceparent(kv.GetStringValue(), low); ok {
+				return high, true
+			}
+		}
+	}
+
+	baggage := lspan.GetSpanContext().GetBaggage()
+	if high, ok := parseHighBits(baggage[traceIDHighKey]); ok {
+		return high, true
+	}
+	return parseTraceparent(baggage[traceparentKey], low)
+}
+
+// parseHighBits parses the hex representation of the high bits.
+func parseHighBits(s string) (uint64, bool) {
+	if s == "" || len(s) > 16 {
+		return 0, false
+	}
+	high, err := strconv.ParseUint(s, 16, 64)
+	return high, err == nil
+}
+
+// parseTraceparent returns the high bits of the trace id of a W3C traceparent
+// ("00-<trace id>-<parent id>-<flags>"), if its low bits match the trace id of the span.
+func parseTraceparent(s string, low uint64) (uint64, bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return 0, false
+	}
+	if !strings.EqualFold(parts[1][16:], fmt.Sprintf("%016x", low)) {
+		return 0, false
+	}
+	return parseHighBits(parts[1][:16])
+}
@@ -0,0 +1,178 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/lightstepreceiver"
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
+)
+
+const TraceIDHigh = 0x4bf92f3577b34da6
+
+func createTraceIDRequest(parentTags []*collectorpb.KeyValue, baggage map[string]string) *collectorpb.ReportRequest {
+	req := createSimpleRequest()
+	req.Spans = []*collectorpb.Span{
+		{
+			OperationName: "parent",
+			SpanContext: &collectorpb.SpanContext{
+				TraceId: TraceID1,
+				SpanId:  SpanID1,
+				Baggage: baggage,
+			},
+			Tags: parentTags,
+		},
+		{
+			OperationName: "child",
+			SpanContext: &collectorpb.SpanContext{
+				TraceId: TraceID1,
+				SpanId:  SpanID2,
+			},
+			References: []*collectorpb.Reference{
+				{SpanContext: &collectorpb.SpanContext{TraceId: TraceID1, SpanId: SpanID1}},
+				{SpanContext: &collectorpb.SpanContext{TraceId: TraceID2, SpanId: SpanID3}},
+			},
+		},
+	}
+	return req
+}
+
+func spansOf(t *testing.T, td ptrace.Traces) ptrace.SpanSlice {
+	require.Equal(t, 1, td.ResourceSpans().Len())
+	return td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+}
+
+func TestRecoverTraceIDHighBits(t *testing.T) {
+	traceparent := "00-4bf92f3577b34da6" + "48485a3953bb6124" + "-00f067aa0ba902b7-01"
+	tests := []struct {
+		name    string
+		tags    []*collectorpb.KeyValue
+		baggage map[string]string
+	}{
+		{
+			name: "hex tag",
+			tags: []*collectorpb.KeyValue{
+				{Key: "otel.trace_id_high", Value: &collectorpb.KeyValue_StringValue{StringValue: "4bf92f3577b34da6"}},
+			},
+		},
+		{
+			name: "int tag",
+			tags: []*collectorpb.KeyValue{
+				{Key: "otel.trace_id_high", Value: &collectorpb.KeyValue_IntValue{IntValue: TraceIDHigh}},
+			},
+		},
+		{
+			name: "traceparent tag",
+			tags: []*collectorpb.KeyValue{
+				{Key: "traceparent", Value: &collectorpb.KeyValue_StringValue{StringValue: traceparent}},
+			},
+		},
+		{
+			name:    "baggage",
+			baggage: map[string]string{"otel.trace_id_high": "4bf92f3577b34da6"},
+		},
+		{
+			name:    "traceparent baggage",
+			baggage: map[string]string{"traceparent": traceparent},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td, err := ToTraces(createTraceIDRequest(tt.tags, tt.baggage), TranslationConfig{})
+			require.NoError(t, err)
+			spans := spansOf(t, td)
+
+			// The child span, and its reference, share the high bits of the parent.
+			want := UInt64ToTraceID(TraceIDHigh, TraceID1)
+			assert.Equal(t, want, spans.At(0).TraceID())
+			assert.Equal(t, want, spans.At(1).TraceID())
+			// The other trace has no high bits.
+			assert.Equal(t, UInt64ToTraceID(0, TraceID2), spans.At(1).Links().At(0).TraceID())
+		})
+	}
+}
+
+func TestTraceparentOfAnotherTrace(t *testing.T) {
+	// The low bits of the traceparent do not match the trace id of the span.
+	td, err := ToTraces(createTraceIDRequest(nil, map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}), TranslationConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, UInt64ToTraceID(0, TraceID1), spansOf(t, td).At(0).TraceID())
+}
+
+func TestSynthesizeTraceIDHighBits(t *testing.T) {
+	td, err := ToTraces(createTraceIDRequest(nil, nil), TranslationConfig{TraceIDHighBits: TraceIDHighBitsHash})
+	require.NoError(t, err)
+	spans := spansOf(t, td)
+
+	traceID := spans.At(0).TraceID()
+	assert.NotEqual(t, UInt64ToTraceID(0, TraceID1), traceID)
+	lowBits := UInt64ToTraceID(0, TraceID1)
+	assert.Equal(t, lowBits[8:], traceID[8:])
+	assert.Equal(t, traceID, spans.At(1).TraceID())
+
+	// The high bits are the same across reports.
+	td, err = ToTraces(createTraceIDRequest(nil, nil), TranslationConfig{TraceIDHighBits: TraceIDHighBitsHash})
+	require.NoError(t, err)
+	assert.Equal(t, traceID, spansOf(t, td).At(0).TraceID())
+
+	link := spans.At(1).Links().At(0).TraceID()
+	assert.NotEqual(t, UInt64ToTraceID(0, TraceID2), link)
+	assert.NotEqual(t, traceID[:8], link[:8])
+}
+
+func TestTraceIDHighBitsAcrossReports(t *testing.T) {
+	cache := newTraceIDCache(traceIDCacheSize, traceIDCacheTTL)
+	tags := []*collectorpb.KeyValue{
+		{Key: "otel.trace_id_high", Value: &collectorpb.KeyValue_StringValue{StringValue: "4bf92f3577b34da6"}},
+	}
+	_, err := toTraces(createTraceIDRequest(tags, nil), TranslationConfig{TraceIDHighBits: TraceIDHighBitsHash}, cache)
+	require.NoError(t, err)
+
+	// The spans of the trace in the next report have the high bits recovered from the first one.
+	req := createTraceIDRequest(nil, nil)
+	req.Spans = req.Spans[1:]
+	td, err := toTraces(req, TranslationConfig{TraceIDHighBits: TraceIDHighBitsHash}, cache)
+	require.NoError(t, err)
+	spans := spansOf(t, td)
+	assert.Equal(t, UInt64ToTraceID(TraceIDHigh, TraceID1), spans.At(0).TraceID())
+	// The high bits of the other trace are still synthesized.
+	assert.Equal(t, UInt64ToTraceID(synthesizeHighBits(TraceIDHighBitsHash, TraceID2), TraceID2), spans.At(0).Links().At(0).TraceID())
+
+	// They are not without the cache.
+	td, err = ToTraces(req, TranslationConfig{TraceIDHighBits: TraceIDHighBitsHash})
+	require.NoError(t, err)
+	assert.Equal(t, UInt64ToTraceID(synthesizeHighBits(TraceIDHighBitsHash, TraceID1), TraceID1), spansOf(t, td).At(0).TraceID())
+}
+
+func TestTraceIDCache(t *testing.T) {
+	cache := newTraceIDCache(2, time.Minute)
+	now := time.Unix(1700000000, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.put(1, 10)
+	cache.put(2, 20)
+	_, ok := cache.get(1)
+	require.True(t, ok)
+
+	// The least recently used entry is evicted once the cache is full.
+	cache.put(3, 30)
+	_, ok = cache.get(2)
+	assert.False(t, ok)
+	high, ok := cache.get(1)
+	assert.True(t, ok)
+	assert.EqualValues(t, 10, high)
+
+	// The entries not used for the ttl are expired.
+	now = now.Add(time.Minute)
+	_, ok = cache.get(3)
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.lru.Len())
+}
@@ -6,6 +6,7 @@ package lightstepreceiver // import "github.com/open-telemetry/opentelemetry-col
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -14,8 +15,12 @@ import (
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
-	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/receiver"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
@@ -25,40 +30,121 @@ import (
 const (
 	ContentType            = "Content-Type"
 	ContentTypeOctetStream = "application/octet-stream"
+
+	// AccessTokenHeader, or the gRPC metadata of the same name, holds the access token
+	// when the tracers do not send it in ReportRequest.Auth.
+	AccessTokenHeader = "Lightstep-Access-Token"
 )
 
 var errNextConsumerRespBody = []byte(`"Internal Server Error"`)
 
-// lightstepReceiver type is used to handle spans received in the Lightstep format.
+// errNextConsumer is returned when the next consumer in the pipeline fails.
+var errNextConsumer = errors.New("next consumer failed")
+
+// errUnknownAccessToken is returned, in the ReportResponse errors, for the reports sent
+// with an access token that is not accepted.
+var errUnknownAccessToken = errors.New("access token is missing or unknown")
+
+// lightstepReceiver type is used to handle reports received in the Lightstep format.
+// The same receiver serves the traces, metrics and logs pipelines using it, see receivers.
 type lightstepReceiver struct {
-	consumer consumer.Traces
+	collectorpb.UnimplementedCollectorServiceServer
 
-	shutdownWG sync.WaitGroup
-	server     *http.Server
-	listener   net.Listener
-	config     *Config
+	nextTraces  consumer.Traces
+	nextMetrics consumer.Metrics
+	nextLogs    consumer.Logs
+
+	shutdownWG   sync.WaitGroup
+	server       *http.Server
+	listener     net.Listener
+	serverGRPC   *grpc.Server
+	listenerGRPC net.Listener
+	config       *Config
+	tokens       *tokenStore
+	// traceIDs keeps the high bits of the trace ids recovered from the reports
+	traceIDs *traceIDCache
 
 	settings receiver.CreateSettings
 }
 
 var _ http.Handler = (*lightstepReceiver)(nil)
+var _ collectorpb.CollectorServiceServer = (*lightstepReceiver)(nil)
 
-// newReceiver creates a new lightstepReceiver reference.
-func newReceiver(config *Config, consumer consumer.Traces, settings receiver.CreateSettings) (*lightstepReceiver, error) {
+// newReceiver creates a new lightstepReceiver reference, with no consumers registered.
+func newReceiver(config *Config, settings receiver.CreateSettings) (*lightstepReceiver, error) {
 	lr := &lightstepReceiver{
-		consumer: consumer,
 		config:   config,
 		settings: settings,
+		traceIDs: newTraceIDCache(traceIDCacheSize, traceIDCacheTTL),
 	}
 	return lr, nil
 }
 
-// Start spins up the receiver's HTTP server and makes the receiver start its processing.
+func (lr *lightstepReceiver) registerTracesConsumer(tc consumer.Traces) {
+	lr.nextTraces = tc
+}
+
+func (lr *lightstepReceiver) registerMetricsConsumer(mc consumer.Metrics) {
+	lr.nextMetrics = mc
+}
+
+func (lr *lightstepReceiver) registerLogsConsumer(lc consumer.Logs) {
+	lr.nextLogs = lc
+}
+
+// Start spins up the receiver's gRPC and HTTP servers and makes the receiver start its processing.
 func (lr *lightstepReceiver) Start(ctx context.Context, host component.Host) error {
 	if host == nil {
 		return errors.New("nil host")
 	}
 
+	if lr.config.AccessTokens != nil {
+		tokens, err := newTokenStore(lr.config.AccessTokens, lr.settings.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to load access tokens: %w", err)
+		}
+		lr.tokens = tokens
+	}
+
+	if lr.config.GRPC != nil {
+		if err := lr.startGRPCServer(ctx, host); err != nil {
+			return err
+		}
+	}
+	if lr.config.HTTP != nil {
+		if err := lr.startHTTPServer(ctx, host); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (lr *lightstepReceiver) startGRPCServer(ctx context.Context, host component.Host) error {
+	var err error
+	lr.serverGRPC, err = lr.config.GRPC.ToServer(ctx, host, lr.settings.TelemetrySettings)
+	if err != nil {
+		return err
+	}
+	collectorpb.RegisterCollectorServiceServer(lr.serverGRPC, lr)
+
+	lr.listenerGRPC, err = lr.config.GRPC.NetAddr.Listen(ctx)
+	if err != nil {
+		return err
+	}
+	lr.shutdownWG.Add(1)
+	go func() {
+		defer lr.shutdownWG.Done()
+
+		if errGRPC := lr.serverGRPC.Serve(lr.listenerGRPC); !errors.Is(errGRPC, grpc.ErrServerStopped) && errGRPC != nil {
+			lr.settings.TelemetrySettings.ReportStatus(component.NewFatalErrorEvent(errGRPC))
+		}
+	}()
+
+	return nil
+}
+
+func (lr *lightstepReceiver) startHTTPServer(ctx context.Context, host component.Host) error {
 	var err error
 	lr.server, err = lr.config.HTTP.ToServer(ctx, host, lr.settings.TelemetrySettings, lr)
 	if err != nil {
@@ -83,7 +169,7 @@ func (lr *lightstepReceiver) Start(ctx context.Context, host component.Host) err
 
 // Shutdown tells the receiver that should stop reception,
 // giving it a chance to perform any necessary clean-up and shutting down
-// its HTTP server.
+// its gRPC and HTTP servers.
 func (lr *lightstepReceiver) Shutdown(context.Context) error {
 	var err error
 	if lr.server != nil {
@@ -92,7 +178,16 @@ func (lr *lightstepReceiver) Shutdown(context.Context) error {
 	if lr.listener != nil {
 		_ = lr.listener.Close()
 	}
+	if lr.serverGRPC != nil {
+		lr.serverGRPC.GracefulStop()
+	}
+	if lr.listenerGRPC != nil {
+		_ = lr.listenerGRPC.Close()
+	}
 	lr.shutdownWG.Wait()
+	if lr.tokens != nil {
+		lr.tokens.shutdown()
+	}
 	return err
 }
 
@@ -118,35 +213,140 @@ func (lr *lightstepReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if token := r.Header.Get(AccessTokenHeader); token != "" && reportRequest.GetAuth().GetAccessToken() == "" {
+		reportRequest.Auth = &collectorpb.Auth{AccessToken: token}
+	}
 
-	var td ptrace.Traces
-	td, err = ToTraces(reportRequest)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	resp, err := lr.consumeReport(ctx, reportRequest, receive)
+	if errors.Is(err, errUnknownAccessToken) {
+		writeReportResponse(w, http.StatusUnauthorized, resp)
 		return
 	}
-
-	consumerErr := lr.consumer.ConsumeTraces(ctx, td)
-
-	if consumerErr != nil {
+	if errors.Is(err, errNextConsumer) {
 		// Transient error, due to some internal condition.
 		w.WriteHeader(http.StatusInternalServerError)
 		_, _ = w.Write(errNextConsumerRespBody)
 		return
 	}
-
-	resp := &collectorpb.ReportResponse{
-		ReceiveTimestamp:  timestamppb.New(receive),
-		TransmitTimestamp: timestamppb.New(time.Now()),
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	// Finally send back the response "Accepted"
+	writeReportResponse(w, http.StatusAccepted, resp)
+}
+
+func writeReportResponse(w http.ResponseWriter, statusCode int, resp *collectorpb.ReportResponse) {
 	bytes, err := proto.Marshal(resp)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Finally send back the response "Accepted"
-	w.WriteHeader(http.StatusAccepted)
 	w.Header().Set(ContentType, ContentTypeOctetStream)
-	w.Write(bytes)
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(bytes)
+}
+
+// Report implements collectorpb.CollectorServiceServer, receiving spans
+// from tracers reporting over gRPC.
+func (lr *lightstepReceiver) Report(ctx context.Context, req *collectorpb.ReportRequest) (*collectorpb.ReportResponse, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && req.GetAuth().GetAccessToken() == "" {
+		if tokens := md.Get(AccessTokenHeader); len(tokens) > 0 {
+			req.Auth = &collectorpb.Auth{AccessToken: tokens[0]}
+		}
+	}
+
+	resp, err := lr.consumeReport(ctx, req, time.Now())
+	if errors.Is(err, errUnknownAccessToken) {
+		// The tracers check the errors of the response.
+		return resp, nil
+	}
+	if errors.Is(err, errNextConsumer) {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return resp, nil
+}
+
+// consumeReport converts the ReportRequest and hands it over to the next consumers,
+// returning the response with the clock correction timestamps. It is shared
+// by both the gRPC and HTTP transports. Only the errors of the traces consumer
+// fail the report, so that the tracers retry it.
+func (lr *lightstepReceiver) consumeReport(ctx context.Context, req *collectorpb.ReportRequest, receive time.Time) (*collectorpb.ReportResponse, error) {
+	if err := lr.authenticate(req); err != nil {
+		return &collectorpb.ReportResponse{
+			Errors:            []string{err.Error()},
+			ReceiveTimestamp:  timestamppb.New(receive),
+			TransmitTimestamp: timestamppb.New(time.Now()),
+		}, err
+	}
+
+	if lr.nextTraces != nil {
+		td, err := toTraces(req, lr.config.Translation, lr.traceIDs)
+		if err != nil {
+			return nil, err
+		}
+		if consumerErr := lr.nextTraces.ConsumeTraces(ctx, td); consumerErr != nil {
+			return nil, fmt.Errorf("%w: %w", errNextConsumer, consumerErr)
+		}
+	}
+
+	if lr.nextMetrics != nil {
+		md, err := ToMetrics(req)
+		if err != nil {
+			return nil, err
+		}
+		// Most reports carry no internal metrics. The report is not failed once the spans are
+		// consumed, as the tracers would report them again.
+		if md.DataPointCount() > 0 {
+			if consumerErr := lr.nextMetrics.ConsumeMetrics(ctx, md); consumerErr != nil {
+				lr.settings.Logger.Warn("Dropping the internal metrics of a report, the next consumer failed", zap.Error(consumerErr))
+			}
+		}
+	}
+
+	if lr.nextLogs != nil {
+		ld, err := toLogs(req, lr.config.Translation, lr.traceIDs)
+		if err != nil {
+			return nil, err
+		}
+		if ld.LogRecordCount() > 0 {
+			if consumerErr := lr.nextLogs.ConsumeLogs(ctx, ld); consumerErr != nil {
+				lr.settings.Logger.Warn("Dropping the span logs of a report, the next consumer failed", zap.Error(consumerErr))
+			}
+		}
+	}
+
+	return &collectorpb.ReportResponse{
+		ReceiveTimestamp:  timestamppb.New(receive),
+		TransmitTimestamp: timestamppb.New(time.Now()),
+	}, nil
+}
+
+// authenticate checks the access token of the report, when access tokens are configured,
+// and adds the project of the token to the reporter tags.
+func (lr *lightstepReceiver) authenticate(req *collectorpb.ReportRequest) error {
+	if lr.tokens == nil {
+		return nil
+	}
+	token := req.GetAuth().GetAccessToken()
+	project, ok := lr.tokens.lookup(token)
+	if !ok || token == "" {
+		return errUnknownAccessToken
+	}
+
+	attr := lr.config.AccessTokens.ProjectAttribute
+	if attr == "" || project == "" || req.Reporter == nil {
+		return nil
+	}
+	// Added last so it overrides any tag of the same name sent by the tracer.
+	req.Reporter.Tags = append(req.Reporter.Tags, &collectorpb.KeyValue{
+		Key:   attr,
+		Value: &collectorpb.KeyValue_StringValue{StringValue: project},
+	})
+	return nil
 }
@@ -6,23 +6,41 @@ package lightstepreceiver
 import (
 	"bytes"
 	"context"
+	"errors"
 	"net"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configgrpc"
 	"go.opentelemetry.io/collector/config/confighttp"
+	"go.opentelemetry.io/collector/config/confignet"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	"go.opentelemetry.io/collector/receiver/receivertest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/lightstep/sn-collector/collector/lightstepreceiver/internal/collectorpb"
 )
 
+// newTracesReceiver returns a receiver serving a traces pipeline only.
+func newTracesReceiver(cfg *Config, nextConsumer consumer.Traces) (*lightstepReceiver, error) {
+	lr, err := newReceiver(cfg, receivertest.NewNopCreateSettings())
+	if err != nil {
+		return nil, err
+	}
+	lr.registerTracesConsumer(nextConsumer)
+	return lr, nil
+}
+
 func TestNew(t *testing.T) {
 	type args struct {
 		address      string
@@ -52,7 +70,7 @@ func TestNew(t *testing.T) {
 				},
 			}
 
-			got, err := newReceiver(cfg, tt.args.nextConsumer, receivertest.NewNopCreateSettings())
+			got, err := newTracesReceiver(cfg, tt.args.nextConsumer)
 			require.Equal(t, tt.wantErr, err)
 			if tt.wantErr == nil {
 				require.NotNil(t, got)
@@ -78,7 +96,29 @@ func TestReceiverPortAlreadyInUse(t *testing.T) {
 			},
 		},
 	}
-	traceReceiver, err := newReceiver(cfg, consumertest.NewNop(), receivertest.NewNopCreateSettings())
+	traceReceiver, err := newTracesReceiver(cfg, consumertest.NewNop())
+	require.NoError(t, err, "Failed to create receiver: %v", err)
+	err = traceReceiver.Start(context.Background(), componenttest.NewNopHost())
+	require.Error(t, err)
+
+	t.Cleanup(func() { require.NoError(t, traceReceiver.Shutdown(context.Background())) })
+}
+
+func TestGRPCReceiverPortAlreadyInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:")
+	require.NoError(t, err, "failed to open a port: %v", err)
+	defer l.Close()
+	cfg := &Config{
+		Protocols: Protocols{
+			GRPC: &configgrpc.ServerConfig{
+				NetAddr: confignet.AddrConfig{
+					Endpoint:  l.Addr().String(),
+					Transport: confignet.TransportTypeTCP,
+				},
+			},
+		},
+	}
+	traceReceiver, err := newTracesReceiver(cfg, consumertest.NewNop())
 	require.NoError(t, err, "Failed to create receiver: %v", err)
 	err = traceReceiver.Start(context.Background(), componenttest.NewNopHost())
 	require.Error(t, err)
@@ -99,7 +139,7 @@ func TestSimpleRequest(t *testing.T) {
 	}
 	sink := new(consumertest.TracesSink)
 
-	traceReceiver, err := newReceiver(cfg, sink, receivertest.NewNopCreateSettings())
+	traceReceiver, err := newTracesReceiver(cfg, sink)
 	require.NoError(t, err, "Failed to create receiver: %v", err)
 	err = traceReceiver.Start(context.Background(), componenttest.NewNopHost())
 	require.NoError(t, err, "Failed to start receiver: %v", err)
@@ -139,6 +179,214 @@ func TestSimpleRequest(t *testing.T) {
 	client.CloseIdleConnections()
 }
 
+func TestSimpleGRPCRequest(t *testing.T) {
+	addr := findAvailableAddress(t)
+	cfg := &Config{
+		Protocols: Protocols{
+			GRPC: &configgrpc.ServerConfig{
+				NetAddr: confignet.AddrConfig{
+					Endpoint:  addr,
+					Transport: confignet.TransportTypeTCP,
+				},
+			},
+		},
+	}
+	sink := new(consumertest.TracesSink)
+
+	traceReceiver, err := newTracesReceiver(cfg, sink)
+	require.NoError(t, err, "Failed to create receiver: %v", err)
+	err = traceReceiver.Start(context.Background(), componenttest.NewNopHost())
+	require.NoError(t, err, "Failed to start receiver: %v", err)
+	t.Cleanup(func() { require.NoError(t, traceReceiver.Shutdown(context.Background())) })
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := collectorpb.NewCollectorServiceClient(conn)
+	resp, err := client.Report(context.Background(), createSimpleRequest())
+	require.NoError(t, err)
+	assert.NotNil(t, resp.GetReceiveTimestamp())
+	assert.NotNil(t, resp.GetTransmitTimestamp())
+
+	traces := sink.AllTraces()
+	require.Equal(t, 1, len(traces))
+	assert.Equal(t, 1, traces[0].SpanCount())
+	span := traces[0].ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0)
+	assert.Equal(t, "span1", span.Name())
+}
+
+func TestGRPCRequestErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		nextConsumer consumer.Traces
+		req          *collectorpb.ReportRequest
+		wantCode     codes.Code
+	}{
+		{
+			name:         "invalid request",
+			nextConsumer: consumertest.NewNop(),
+			req:          &collectorpb.ReportRequest{},
+			wantCode:     codes.InvalidArgument,
+		},
+		{
+			name:         "next consumer error",
+			nextConsumer: consumertest.NewErr(errors.New("consumer error")),
+			req:          createSimpleRequest(),
+			wantCode:     codes.Unavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := findAvailableAddress(t)
+			cfg := &Config{
+				Protocols: Protocols{
+					GRPC: &configgrpc.ServerConfig{
+						NetAddr: confignet.AddrConfig{
+							Endpoint:  addr,
+							Transport: confignet.TransportTypeTCP,
+						},
+					},
+				},
+			}
+
+			traceReceiver, err := newTracesReceiver(cfg, tt.nextConsumer)
+			require.NoError(t, err, "Failed to create receiver: %v", err)
+			require.NoError(t, traceReceiver.Start(context.Background(), componenttest.NewNopHost()))
+			t.Cleanup(func() { require.NoError(t, traceReceiver.Shutdown(context.Background())) })
+
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = collectorpb.NewCollectorServiceClient(conn).Report(context.Background(), tt.req)
+			require.Error(t, err)
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+func TestTracesMetricsAndLogsPipelines(t *testing.T) {
+	addr := findAvailableAddress(t)
+	cfg := &Config{
+		Protocols: Protocols{
+			GRPC: &configgrpc.ServerConfig{
+				NetAddr: confignet.AddrConfig{
+					Endpoint:  addr,
+					Transport: confignet.TransportTypeTCP,
+				},
+			},
+		},
+	}
+	tracesSink := new(consumertest.TracesSink)
+	metricsSink := new(consumertest.MetricsSink)
+	logsSink := new(consumertest.LogsSink)
+
+	// All the pipelines are served by the same receiver, started once.
+	factory := NewFactory()
+	tracesReceiver, err := factory.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, tracesSink)
+	require.NoError(t, err)
+	metricsReceiver, err := factory.CreateMetricsReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, metricsSink)
+	require.NoError(t, err)
+	logsReceiver, err := factory.CreateLogsReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, logsSink)
+	require.NoError(t, err)
+	assert.Same(t, tracesReceiver, metricsReceiver)
+	assert.Same(t, tracesReceiver, logsReceiver)
+	require.NoError(t, tracesReceiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, metricsReceiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, logsReceiver.Start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() {
+		require.NoError(t, tracesReceiver.Shutdown(context.Background()))
+		require.NoError(t, metricsReceiver.Shutdown(context.Background()))
+		require.NoError(t, logsReceiver.Shutdown(context.Background()))
+	})
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := collectorpb.NewCollectorServiceClient(conn)
+	req := createSimpleRequest()
+	req.InternalMetrics = &collectorpb.InternalMetrics{
+		Counts: []*collectorpb.MetricsSample{
+			{
+				Name:  "spans.dropped",
+				Value: &collectorpb.MetricsSample_IntValue{IntValue: 3},
+			},
+		},
+	}
+	req.Spans[0].Logs = []*collectorpb.Log{
+		{
+			Fields: []*collectorpb.KeyValue{
+				{
+					Key:   "message",
+					Value: &collectorpb.KeyValue_StringValue{StringValue: "cache miss"},
+				},
+			},
+		},
+	}
+	_, err = client.Report(context.Background(), req)
+	require.NoError(t, err)
+
+	// Reports without internal metrics or span logs are not sent to the metrics and logs pipelines.
+	_, err = client.Report(context.Background(), createSimpleRequest())
+	require.NoError(t, err)
+
+	assert.Len(t, tracesSink.AllTraces(), 2)
+	metrics := metricsSink.AllMetrics()
+	require.Len(t, metrics, 1)
+	metric := metrics[0].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	assert.Equal(t, "spans.dropped", metric.Name())
+	assert.Equal(t, int64(3), metric.Sum().DataPoints().At(0).IntValue())
+	logs := logsSink.AllLogs()
+	require.Len(t, logs, 1)
+	assert.Equal(t, "cache miss", logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Str())
+}
+
+func TestConsumeReportConsumerErrors(t *testing.T) {
+	req := createSimpleRequest()
+	req.InternalMetrics = &collectorpb.InternalMetrics{
+		Counts: []*collectorpb.MetricsSample{
+			{
+				Name:  "spans.dropped",
+				Value: &collectorpb.MetricsSample_IntValue{IntValue: 3},
+			},
+		},
+	}
+	req.Spans[0].Logs = []*collectorpb.Log{
+		{
+			Fields: []*collectorpb.KeyValue{
+				{
+					Key:   "message",
+					Value: &collectorpb.KeyValue_StringValue{StringValue: "cache miss"},
+				},
+			},
+		},
+	}
+
+	// The report is accepted once its spans are consumed, a retry would duplicate them.
+	tracesSink := new(consumertest.TracesSink)
+	lr, err := newTracesReceiver(&Config{}, tracesSink)
+	require.NoError(t, err)
+	lr.registerMetricsConsumer(consumertest.NewErr(errors.New("consumer error")))
+	lr.registerLogsConsumer(consumertest.NewErr(errors.New("consumer error")))
+	_, err = lr.consumeReport(context.Background(), req, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, tracesSink.AllTraces(), 1)
+
+	// The report is retried when the spans are not consumed.
+	lr, err = newTracesReceiver(&Config{}, consumertest.NewErr(errors.New("consumer error")))
+	require.NoError(t, err)
+	metricsSink := new(consumertest.MetricsSink)
+	lr.registerMetricsConsumer(metricsSink)
+	logsSink := new(consumertest.LogsSink)
+	lr.registerLogsConsumer(logsSink)
+	_, err = lr.consumeReport(context.Background(), req, time.Now())
+	assert.ErrorIs(t, err, errNextConsumer)
+	assert.Empty(t, metricsSink.AllMetrics())
+	assert.Empty(t, logsSink.AllLogs())
+}
+
 func createHttpRequest(addr string, req *collectorpb.ReportRequest) (*http.Request, error) {
 	buff, err := proto.Marshal(req)
 	if err != nil {
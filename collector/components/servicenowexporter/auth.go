@@ -0,0 +1,197 @@
+package servicenowexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const (
+	grantTypeClientCredentials = "client_credentials"
+	grantTypePassword          = "password"
+)
+
+// OAuth2Config authenticates against a ServiceNow OAuth2 application registry entry.
+type OAuth2Config struct {
+	// TokenURL is the ServiceNow token endpoint. Ex: https://INSTANCE_NAME.service-now.com/oauth_token.do
+	TokenURL string `mapstructure:"token_url"`
+
+	// ClientID and ClientSecret identify the OAuth2 application
+	ClientID     string              `mapstructure:"client_id"`
+	ClientSecret configopaque.String `mapstructure:"client_secret"`
+
+	// GrantType is either client_credentials (default) or password
+	GrantType string `mapstructure:"grant_type"`
+
+	// Username and Password are the user credentials used by the password grant
+	Username string              `mapstructure:"username"`
+	Password configopaque.String `mapstructure:"password"`
+
+	// Scopes optionally requested for the access token
+	Scopes []string `mapstructure:"scopes"`
+}
+
+func (cfg *OAuth2Config) Validate() error {
+	if cfg.TokenURL == "" {
+		return errors.New("oauth2: token_url must be specified")
+	}
+	if cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return errors.New("oauth2: client_id and client_secret must be specified")
+	}
+	switch cfg.GrantType {
+	case "", grantTypeClientCredentials:
+	case grantTypePassword:
+		if cfg.Username == "" || cfg.Password == "" {
+			return errors.New("oauth2: username and password must be specified for the password grant")
+		}
+	default:
+		return fmt.Errorf("oauth2: unsupported grant_type %q, must be %s or %s", cfg.GrantType, grantTypeClientCredentials, grantTypePassword)
+	}
+	return nil
+}
+
+// requestAuthenticator sets the credentials on every request sent to ServiceNow,
+// regardless of the endpoint (events, logs or metrics).
+type requestAuthenticator interface {
+	authenticate(r *http.Request) error
+}
+
+// newRequestAuthenticator picks the authentication method from the config. Methods are
+// checked in order: collector auth extension, OAuth2, bearer token, basic auth and API key.
+// tokenClient is only used to request OAuth2 tokens.
+func newRequestAuthenticator(config *Config, tokenClient *http.Client) requestAuthenticator {
+	switch {
+	case config.Auth != nil:
+		// The authenticator extension is already part of the HTTP client transport.
+		return noAuth{}
+	case config.OAuth2 != nil:
+		return &oauth2Auth{source: newOAuth2TokenSource(config.OAuth2, tokenClient)}
+	case config.BearerToken != "":
+		return headerAuth{value: "Bearer " + string(config.BearerToken)}
+	case config.Username != "":
+		return basicAuth{username: config.Username, password: string(config.Password)}
+	case config.ApiKey != "":
+		return headerAuth{value: "key " + string(config.ApiKey)}
+	}
+	return noAuth{}
+}
+
+type noAuth struct{}
+
+func (noAuth) authenticate(*http.Request) error {
+	return nil
+}
+
+type basicAuth struct {
+	username string
+	password string
+}
+
+func (a basicAuth) authenticate(r *http.Request) error {
+	r.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+// headerAuth sets a fixed Authorization header, used for API keys and bearer tokens.
+type headerAuth struct {
+	value string
+}
+
+func (a headerAuth) authenticate(r *http.Request) error {
+	r.Header.Set("Authorization", a.value)
+	return nil
+}
+
+type oauth2Auth struct {
+	source oauth2.TokenSource
+}
+
+func (a *oauth2Auth) authenticate(r *http.Request) error {
+	token, err := a.source.Token()
+	if err != nil {
+		err = fmt.Errorf("failed to get OAuth2 token: %w", err)
+		// Rejected credentials won't be accepted on retry either.
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.Response != nil &&
+			retrieveErr.Response.StatusCode >= 400 && retrieveErr.Response.StatusCode < 500 {
+			return consumererror.NewPermanent(err)
+		}
+		return err
+	}
+	token.SetAuthHeader(r)
+	return nil
+}
+
+// newOAuth2TokenSource returns a token source caching the token until it expires. The tokens are
+// requested for the lifetime of the exporter, not bound to the context of its start.
+func newOAuth2TokenSource(cfg *OAuth2Config, tokenClient *http.Client) oauth2.TokenSource {
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, tokenClient)
+	if cfg.GrantType == grantTypePassword {
+		return oauth2.ReuseTokenSource(nil, &passwordTokenSource{
+			ctx: ctx,
+			config: &oauth2.Config{
+				ClientID:     cfg.ClientID,
+				ClientSecret: string(cfg.ClientSecret),
+				Endpoint: oauth2.Endpoint{
+					TokenURL:  cfg.TokenURL,
+					AuthStyle: oauth2.AuthStyleInParams,
+				},
+				Scopes: cfg.Scopes,
+			},
+			username: cfg.Username,
+			password: string(cfg.Password),
+		})
+	}
+
+	ccConfig := &clientcredentials.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: string(cfg.ClientSecret),
+		TokenURL:     cfg.TokenURL,
+		Scopes:       cfg.Scopes,
+		AuthStyle:    oauth2.AuthStyleInParams,
+	}
+	return ccConfig.TokenSource(ctx)
+}
+
+// passwordTokenSource uses the refresh token to get new access tokens, falling
+// back to the password grant when there is none or it has expired as well.
+type passwordTokenSource struct {
+	ctx      context.Context
+	config   *oauth2.Config
+	username string
+	password string
+
+	mu      sync.Mutex
+	refresh oauth2.TokenSource
+}
+
+func (s *passwordTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.refresh != nil {
+		if token, err := s.refresh.Token(); err == nil {
+			return token, nil
+		}
+		s.refresh = nil
+	}
+
+	token, err := s.config.PasswordCredentialsToken(s.ctx, s.username, s.password)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken != "" {
+		// Expire the token right away so the refresh source only uses it to refresh.
+		expired := *token
+		expired.AccessToken = ""
+		s.refresh = s.config.TokenSource(s.ctx, &expired)
+	}
+	return token, nil
+}
@@ -0,0 +1,203 @@
+package servicenowexporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+)
+
+// authServerMock records the Authorization header of the last request received.
+func authServerMock(t *testing.T, authorization *string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthenticationMethods(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(cfg *Config)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:   "basic",
+			config: func(cfg *Config) { cfg.Username, cfg.Password = "admin", "secret" },
+			want:   "Basic YWRtaW46c2VjcmV0",
+		},
+		{
+			name:   "api key",
+			config: func(cfg *Config) { cfg.ApiKey = "abc" },
+			want:   "key abc",
+		},
+		{
+			name:   "bearer token",
+			config: func(cfg *Config) { cfg.BearerToken = "token" },
+			want:   "Bearer token",
+		},
+		{
+			name: "basic takes precedence over api key",
+			config: func(cfg *Config) {
+				cfg.Username, cfg.Password = "admin", "secret"
+				cfg.ApiKey = "abc"
+			},
+			want: "Basic YWRtaW46c2VjcmV0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authorization string
+			server := authServerMock(t, &authorization)
+
+			cfg := createDefaultConfig().(*Config)
+			cfg.PushEventsURL = server.URL
+			cfg.PushLogsURL = server.URL
+			cfg.PushMetricsURL = server.URL
+			if tt.config != nil {
+				tt.config(cfg)
+			}
+			client := newTestMidClient(t, cfg)
+
+			// All the endpoints are authenticated the same way.
+			_, err := client.sendEvents(context.Background(), createTestEvents(1))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, authorization)
+			require.NoError(t, client.sendLogs(context.Background(), []ServiceNowLog{{Body: "test"}}))
+			assert.Equal(t, tt.want, authorization)
+			require.NoError(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test"}}))
+			assert.Equal(t, tt.want, authorization)
+		})
+	}
+}
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var tokenRequests atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, grantTypeClientCredentials, r.PostForm.Get("grant_type"))
+		assert.Equal(t, "id", r.PostForm.Get("client_id"))
+		assert.Equal(t, "secret", r.PostForm.Get("client_secret"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+
+	var authorization string
+	server := authServerMock(t, &authorization)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushMetricsURL = server.URL
+	cfg.OAuth2 = &OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"}
+	client := newTestMidClient(t, cfg)
+
+	require.NoError(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test"}}))
+	require.NoError(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test"}}))
+	assert.Equal(t, "Bearer access", authorization)
+	assert.EqualValues(t, 1, tokenRequests.Load(), "token should be reused until it expires")
+}
+
+func TestOAuth2AfterStartContextDone(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer tokenServer.Close()
+
+	var authorization string
+	server := authServerMock(t, &authorization)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushMetricsURL = server.URL
+	cfg.OAuth2 = &OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"}
+	settings := componenttest.NewNopTelemetrySettings()
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := newMidClient(ctx, componenttest.NewNopHost(), settings, newTestTelemetryBuilder(t, settings), cfg)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	// The context of the start is done once the exporter is started.
+	cancel()
+	require.NoError(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test"}}))
+	assert.Equal(t, "Bearer access", authorization)
+}
+
+func TestOAuth2PasswordRefresh(t *testing.T) {
+	var grants []string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		grants = append(grants, r.PostForm.Get("grant_type"))
+		w.Header().Set("Content-Type", "application/json")
+		// Tokens expire right away so every request needs a new one.
+		fmt.Fprintf(w, `{"access_token":"access-%d","refresh_token":"refresh","token_type":"Bearer","expires_in":1}`, len(grants))
+	}))
+	defer tokenServer.Close()
+
+	var authorization string
+	server := authServerMock(t, &authorization)
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushMetricsURL = server.URL
+	cfg.OAuth2 = &OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		GrantType:    grantTypePassword,
+		Username:     "admin",
+		Password:     "secret",
+	}
+	client := newTestMidClient(t, cfg)
+
+	require.NoError(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test"}}))
+	assert.Equal(t, "Bearer access-1", authorization)
+	require.NoError(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test"}}))
+	assert.Equal(t, "Bearer access-2", authorization)
+	assert.Equal(t, []string{grantTypePassword, "refresh_token"}, grants)
+}
+
+func TestOAuth2RejectedCredentialsArePermanent(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"access_denied"}`)
+	}))
+	defer tokenServer.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.OAuth2 = &OAuth2Config{TokenURL: tokenServer.URL, ClientID: "id", ClientSecret: "secret"}
+	client := newTestMidClient(t, cfg)
+
+	err := client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test"}})
+	require.Error(t, err)
+	assert.True(t, consumererror.IsPermanent(err))
+}
+
+func TestOAuth2ConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.OAuth2 = &OAuth2Config{TokenURL: "https://example.service-now.com/oauth_token.do", ClientID: "id", ClientSecret: "secret"}
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.OAuth2.GrantType = grantTypePassword
+	assert.EqualError(t, component.ValidateConfig(cfg), "oauth2: username and password must be specified for the password grant")
+
+	cfg.OAuth2.GrantType = "implicit"
+	assert.EqualError(t, component.ValidateConfig(cfg), `oauth2: unsupported grant_type "implicit", must be client_credentials or password`)
+
+	cfg.OAuth2.TokenURL = ""
+	assert.EqualError(t, component.ValidateConfig(cfg), "oauth2: token_url must be specified")
+}
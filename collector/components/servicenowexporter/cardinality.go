@@ -0,0 +1,403 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"github.com/lightstep/sn-collector/collector/servicenowexporter/internal/metadata"
+)
+
+const (
+	// cardinalityActionCollapse replaces the values of the offending attribute with overflowValue.
+	cardinalityActionCollapse = "collapse"
+	// cardinalityActionDrop removes the offending attribute.
+	cardinalityActionDrop = "drop"
+
+	overflowValue = "__overflow__"
+)
+
+// CardinalityConfig limits the number of distinct resource paths sent per metric. When a new resource
+// path would exceed the limit, the data point attribute with the most distinct values is collapsed
+// into an __overflow__ value, or dropped, for the rest of the window. The data points of a batch
+// ending up in the same series at the same timestamp are merged: sums and histograms are added,
+// while gauges and summaries keep the last data point.
+type CardinalityConfig struct {
+	// MaxResourcePaths is the maximum number of distinct resource paths per metric in a window, 0 disables the limit
+	MaxResourcePaths int `mapstructure:"max_resource_paths"`
+
+	// Window is how long the resource paths are tracked before starting over
+	Window time.Duration `mapstructure:"window"`
+
+	// Action is collapse (default) or drop
+	Action string `mapstructure:"action"`
+}
+
+func (cfg *CardinalityConfig) Validate() error {
+	if cfg.MaxResourcePaths < 0 {
+		return errors.New("cardinality: max_resource_paths must not be negative")
+	}
+	if cfg.MaxResourcePaths > 0 && cfg.Window <= 0 {
+		return errors.New("cardinality: window must be greater than 0")
+	}
+	switch cfg.Action {
+	case cardinalityActionCollapse, cardinalityActionDrop:
+	default:
+		return fmt.Errorf("cardinality: unsupported action %q, must be %s or %s", cfg.Action, cardinalityActionCollapse, cardinalityActionDrop)
+	}
+	return nil
+}
+
+// metricCardinality tracks the resource paths of a metric in the current window.
+type metricCardinality struct {
+	windowStart time.Time
+	lastSeen    time.Time
+	paths       map[string]struct{}
+	// values holds the distinct values of each attribute
+	values   map[string]map[string]struct{}
+	overflow map[string]bool
+}
+
+// cardinalityLimiter applies the CardinalityConfig.
+type cardinalityLimiter struct {
+	config   CardinalityConfig
+	mapper   *attributeMapper
+	logger   *zap.Logger
+	overflow metric.Int64Counter
+
+	mu        sync.Mutex
+	metrics   map[string]*metricCardinality
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newCardinalityLimiter(cfg CardinalityConfig, mapper *attributeMapper, logger *zap.Logger, telemetry *metadata.TelemetryBuilder) *cardinalityLimiter {
+	return &cardinalityLimiter{
+		config:   cfg,
+		mapper:   mapper,
+		logger:   logger,
+		overflow: telemetry.ServicenowExporterCardinalityOverflow,
+		metrics:  make(map[string]*metricCardinality),
+		now:      time.Now,
+	}
+}
+
+// limit returns the metric with the attributes of its data points limited. The metric is copied
+// when the limit is enabled, to leave the original data unchanged.
+func (l *cardinalityLimiter) limit(ctx context.Context, metricName string, m pmetric.Metric) pmetric.Metric {
+	if l.config.MaxResourcePaths == 0 {
+		return m
+	}
+
+	limited := pmetric.NewMetric()
+	m.CopyTo(limited)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	state := l.metricState(metricName)
+	overflowCounts := make(map[string]int64)
+	forEachDataPointAttributes(limited, func(attrs pcommon.Map) {
+		for _, key := range l.process(metricName, state, attrs) {
+			overflowCounts[key]++
+		}
+	})
+	for key, count := range overflowCounts {
+		l.overflow.Add(ctx, count, metric.WithAttributes(
+			attribute.String("metric", metricName),
+			attribute.String("attribute", key),
+		))
+	}
+	if len(overflowCounts) > 0 {
+		mergeDataPoints(limited)
+	}
+	return limited
+}
+
+func (l *cardinalityLimiter) metricState(metricName string) *metricCardinality {
+	now := l.now()
+	l.sweep(now)
+	state, ok := l.metrics[metricName]
+	if !ok || now.Sub(state.windowStart) >= l.config.Window {
+		state = &metricCardinality{
+			windowStart: now,
+			paths:       make(map[string]struct{}),
+			values:      make(map[string]map[string]struct{}),
+			overflow:    make(map[string]bool),
+		}
+		l.metrics[metricName] = state
+	}
+	state.lastSeen = now
+	return state
+}
+
+// sweep forgets the metrics not seen for a window, at most once per window.
+func (l *cardinalityLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.config.Window {
+		return
+	}
+	l.lastSweep = now
+	for name, state := range l.metrics {
+		if now.Sub(state.lastSeen) >= l.config.Window {
+			delete(l.metrics, name)
+		}
+	}
+}
+
+// process limits the attributes of a data point and returns the keys collapsed or dropped.
+func (l *cardinalityLimiter) process(metricName string, state *metricCardinality, attrs pcommon.Map) []string {
+	for {
+		overflowed := l.applyOverflow(state, attrs)
+		path := l.mapper.buildPath(metricName, attrs)
+		if _, ok := state.paths[path]; ok || len(state.paths) < l.config.MaxResourcePaths {
+			state.paths[path] = struct{}{}
+			l.recordValues(state, attrs)
+			return overflowed
+		}
+
+		key, values := l.offendingKey(state, attrs)
+		if values <= 1 {
+			// No attribute has more than a single value, collapsing would not reduce the cardinality.
+			state.paths[path] = struct{}{}
+			l.recordValues(state, attrs)
+			return overflowed
+		}
+		state.overflow[key] = true
+		l.logger.Warn("Resource path cardinality limit reached, limiting attribute",
+			zap.String("metric", metricName),
+			zap.String("attribute", key),
+			zap.String("action", l.config.Action),
+			zap.Int("max_resource_paths", l.config.MaxResourcePaths))
+	}
+}
+
+// applyOverflow collapses or drops the attributes over the limit.
+func (l *cardinalityLimiter) applyOverflow(state *metricCardinality, attrs pcommon.Map) []string {
+	var keys []string
+	for key := range state.overflow {
+		v, ok := attrs.Get(key)
+		if !ok {
+			continue
+		}
+		if l.config.Action == cardinalityActionDrop {
+			attrs.Remove(key)
+		} else if v.Type() == pcommon.ValueTypeStr && v.Str() == overflowValue {
+			continue
+		} else {
+			attrs.PutStr(key, overflowValue)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (l *cardinalityLimiter) recordValues(state *metricCardinality, attrs pcommon.Map) {
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if state.overflow[k] {
+			return true
+		}
+		if state.values[k] == nil {
+			state.values[k] = make(map[string]struct{})
+		}
+		state.values[k][v.AsString()] = struct{}{}
+		return true
+	})
+}
+
+// offendingKey returns the attribute of the data point with the most distinct values, counting its own,
+// and its number of values.
+func (l *cardinalityLimiter) offendingKey(state *metricCardinality, attrs pcommon.Map) (string, int) {
+	key := ""
+	maxValues := -1
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		if state.overflow[k] {
+			return true
+		}
+		count := len(state.values[k])
+		if _, ok := state.values[k][v.AsString()]; !ok {
+			count++
+		}
+		if count > maxValues || (count == maxValues && k < key) {
+			key, maxValues = k, count
+		}
+		return true
+	})
+	return key, maxValues
+}
+
+// forEachDataPointAttributes calls fn with the attributes of every data point of the metric.
+func forEachDataPointAttributes(m pmetric.Metric, fn func(pcommon.Map)) {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		for i := 0; i < m.Gauge().DataPoints().Len(); i++ {
+			fn(m.Gauge().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSum:
+		for i := 0; i < m.Sum().DataPoints().Len(); i++ {
+			fn(m.Sum().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeHistogram:
+		for i := 0; i < m.Histogram().DataPoints().Len(); i++ {
+			fn(m.Histogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		for i := 0; i < m.ExponentialHistogram().DataPoints().Len(); i++ {
+			fn(m.ExponentialHistogram().DataPoints().At(i).Attributes())
+		}
+	case pmetric.MetricTypeSummary:
+		for i := 0; i < m.Summary().DataPoints().Len(); i++ {
+			fn(m.Summary().DataPoints().At(i).Attributes())
+		}
+	}
+}
+
+// dataPointKey identifies the data points of a series at a timestamp.
+func dataPointKey(attrs pcommon.Map, timestamp pcommon.Timestamp) string {
+	// json sorts the map keys, so the key doesn't depend on the attributes order.
+	key, _ := json.Marshal([]any{attrs.AsRaw(), timestamp})
+	return string(key)
+}
+
+// mergeDataPoints merges the data points of the metric sharing their attributes and timestamp,
+// which happens once attributes are collapsed or dropped.
+func mergeDataPoints(m pmetric.Metric) {
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		mergeNumberDataPoints(m.Gauge().DataPoints(), false)
+	case pmetric.MetricTypeSum:
+		mergeNumberDataPoints(m.Sum().DataPoints(), true)
+	case pmetric.MetricTypeHistogram:
+		mergeHistogramDataPoints(m.Histogram().DataPoints())
+	case pmetric.MetricTypeExponentialHistogram:
+		mergeExponentialHistogramDataPoints(m.ExponentialHistogram().DataPoints())
+	case pmetric.MetricTypeSummary:
+		dps := m.Summary().DataPoints()
+		last := make(map[string]int, dps.Len())
+		for i := 0; i < dps.Len(); i++ {
+			last[dataPointKey(dps.At(i).Attributes(), dps.At(i).Timestamp())] = i
+		}
+		i := 0
+		dps.RemoveIf(func(dp pmetric.SummaryDataPoint) bool {
+			keep := last[dataPointKey(dp.Attributes(), dp.Timestamp())] == i
+			i++
+			return !keep
+		})
+	}
+}
+
+// mergeNumberDataPoints adds the values of the merged data points when add is set, keeping
+// the last value otherwise.
+func mergeNumberDataPoints(dps pmetric.NumberDataPointSlice, add bool) {
+	merged := make(map[string]pmetric.NumberDataPoint, dps.Len())
+	dps.RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+		key := dataPointKey(dp.Attributes(), dp.Timestamp())
+		first, ok := merged[key]
+		if !ok {
+			merged[key] = dp
+			return false
+		}
+		switch {
+		case !add:
+			dp.CopyTo(first)
+		case first.ValueType() == pmetric.NumberDataPointValueTypeInt && dp.ValueType() == pmetric.NumberDataPointValueTypeInt:
+			first.SetIntValue(first.IntValue() + dp.IntValue())
+		default:
+			a, _ := numberValue(first)
+			b, _ := numberValue(dp)
+			first.SetDoubleValue(a + b)
+		}
+		return true
+	})
+}
+
+// mergeHistogramDataPoints adds the histograms with the same bucket boundaries, the last one
+// being kept otherwise.
+func mergeHistogramDataPoints(dps pmetric.HistogramDataPointSlice) {
+	merged := make(map[string]pmetric.HistogramDataPoint, dps.Len())
+	dps.RemoveIf(func(dp pmetric.HistogramDataPoint) bool {
+		key := dataPointKey(dp.Attributes(), dp.Timestamp())
+		first, ok := merged[key]
+		if !ok {
+			merged[key] = dp
+			return false
+		}
+		if !slices.Equal(first.ExplicitBounds().AsRaw(), dp.ExplicitBounds().AsRaw()) || first.BucketCounts().Len() != dp.BucketCounts().Len() {
+			dp.CopyTo(first)
+			return true
+		}
+		for i := 0; i < dp.BucketCounts().Len(); i++ {
+			first.BucketCounts().SetAt(i, first.BucketCounts().At(i)+dp.BucketCounts().At(i))
+		}
+		first.SetCount(first.Count() + dp.Count())
+		first.SetSum(first.Sum() + dp.Sum())
+		if dp.HasMin() && (!first.HasMin() || dp.Min() < first.Min()) {
+			first.SetMin(dp.Min())
+		}
+		if dp.HasMax() && (!first.HasMax() || dp.Max() > first.Max()) {
+			first.SetMax(dp.Max())
+		}
+		return true
+	})
+}
+
+// mergeExponentialHistogramDataPoints adds the exponential histograms with the same scale, the
+// last one being kept otherwise.
+func mergeExponentialHistogramDataPoints(dps pmetric.ExponentialHistogramDataPointSlice) {
+	merged := make(map[string]pmetric.ExponentialHistogramDataPoint, dps.Len())
+	dps.RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool {
+		key := dataPointKey(dp.Attributes(), dp.Timestamp())
+		first, ok := merged[key]
+		if !ok {
+			merged[key] = dp
+			return false
+		}
+		if first.Scale() != dp.Scale() {
+			dp.CopyTo(first)
+			return true
+		}
+		mergeExponentialBuckets(first.Positive(), dp.Positive())
+		mergeExponentialBuckets(first.Negative(), dp.Negative())
+		first.SetZeroCount(first.ZeroCount() + dp.ZeroCount())
+		first.SetCount(first.Count() + dp.Count())
+		first.SetSum(first.Sum() + dp.Sum())
+		if dp.HasMin() && (!first.HasMin() || dp.Min() < first.Min()) {
+			first.SetMin(dp.Min())
+		}
+		if dp.HasMax() && (!first.HasMax() || dp.Max() > first.Max()) {
+			first.SetMax(dp.Max())
+		}
+		return true
+	})
+}
+
+// mergeExponentialBuckets adds the counts of src to dst, both having the same scale.
+func mergeExponentialBuckets(dst pmetric.ExponentialHistogramDataPointBuckets, src pmetric.ExponentialHistogramDataPointBuckets) {
+	if src.BucketCounts().Len() == 0 {
+		return
+	}
+	if dst.BucketCounts().Len() == 0 {
+		src.CopyTo(dst)
+		return
+	}
+	offset := min(dst.Offset(), src.Offset())
+	end := max(dst.Offset()+int32(dst.BucketCounts().Len()), src.Offset()+int32(src.BucketCounts().Len()))
+	counts := make([]uint64, end-offset)
+	for i := 0; i < dst.BucketCounts().Len(); i++ {
+		counts[dst.Offset()-offset+int32(i)] += dst.BucketCounts().At(i)
+	}
+	for i := 0; i < src.BucketCounts().Len(); i++ {
+		counts[src.Offset()-offset+int32(i)] += src.BucketCounts().At(i)
+	}
+	dst.SetOffset(offset)
+	dst.BucketCounts().FromRaw(counts)
+}
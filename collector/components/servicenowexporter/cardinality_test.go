@@ -0,0 +1,194 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// createRequestsMetric returns a gauge with a data point per user.
+func createRequestsMetric(users ...string) pmetric.Metric {
+	metric := pmetric.NewMetric()
+	metric.SetName("http.requests")
+	gauge := metric.SetEmptyGauge()
+	for _, user := range users {
+		dp := gauge.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("http.method", "GET")
+		dp.Attributes().PutStr("user.id", user)
+		dp.SetIntValue(1)
+	}
+	return metric
+}
+
+func resourcePaths(t *testing.T, metric pmetric.Metric) []string {
+	mapper := newTestAttributeMapper(t, MappingConfig{})
+	var paths []string
+	forEachDataPointAttributes(metric, func(attrs pcommon.Map) {
+		paths = append(paths, mapper.buildPath(metric.Name(), attrs))
+	})
+	return paths
+}
+
+func TestCardinalityLimiterCollapse(t *testing.T) {
+	settings, reader := newTestTelemetrySettings()
+	limiter := newCardinalityLimiter(CardinalityConfig{MaxResourcePaths: 2, Window: time.Minute, Action: cardinalityActionCollapse},
+		newTestAttributeMapper(t, MappingConfig{}), settings.Logger, newTestTelemetryBuilder(t, settings))
+
+	metric := createRequestsMetric("1", "2", "3", "4")
+	limited := limiter.limit(context.Background(), "http.requests", metric)
+	// The collapsed data points are merged.
+	assert.Equal(t, []string{
+		"http.requests;http.method=GET;user.id=1",
+		"http.requests;http.method=GET;user.id=2",
+		"http.requests;http.method=GET;user.id=__overflow__",
+	}, resourcePaths(t, limited))
+	// The original data is left unchanged.
+	assert.Equal(t, "http.requests;http.method=GET;user.id=3", resourcePaths(t, metric)[2])
+
+	// The attribute stays collapsed for the rest of the window.
+	limited = limiter.limit(context.Background(), "http.requests", createRequestsMetric("1", "5"))
+	assert.Equal(t, []string{
+		"http.requests;http.method=GET;user.id=__overflow__",
+	}, resourcePaths(t, limited))
+
+	sum := collectMetric(t, reader, "servicenow_exporter_cardinality_overflow").Data.(metricdata.Sum[int64])
+	require.Len(t, sum.DataPoints, 1)
+	assert.EqualValues(t, 4, sum.DataPoints[0].Value)
+	userID, _ := sum.DataPoints[0].Attributes.Value("attribute")
+	assert.Equal(t, "user.id", userID.AsString())
+}
+
+func TestCardinalityLimiterDropAndWindow(t *testing.T) {
+	settings := componenttest.NewNopTelemetrySettings()
+	limiter := newCardinalityLimiter(CardinalityConfig{MaxResourcePaths: 1, Window: time.Minute, Action: cardinalityActionDrop},
+		newTestAttributeMapper(t, MappingConfig{}), settings.Logger, newTestTelemetryBuilder(t, settings))
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	limited := limiter.limit(context.Background(), "http.requests", createRequestsMetric("1", "2"))
+	assert.Equal(t, []string{
+		"http.requests;http.method=GET;user.id=1",
+		"http.requests;http.method=GET",
+	}, resourcePaths(t, limited))
+
+	now = now.Add(time.Minute)
+	limited = limiter.limit(context.Background(), "http.requests", createRequestsMetric("2"))
+	assert.Equal(t, []string{"http.requests;http.method=GET;user.id=2"}, resourcePaths(t, limited))
+}
+
+func TestCardinalityLimiterForgetsMetrics(t *testing.T) {
+	settings := componenttest.NewNopTelemetrySettings()
+	limiter := newCardinalityLimiter(CardinalityConfig{MaxResourcePaths: 1, Window: time.Minute, Action: cardinalityActionCollapse},
+		newTestAttributeMapper(t, MappingConfig{}), settings.Logger, newTestTelemetryBuilder(t, settings))
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	limiter.limit(context.Background(), "http.requests", createRequestsMetric("1"))
+	now = now.Add(30 * time.Second)
+	limiter.limit(context.Background(), "http.responses", createRequestsMetric("1"))
+	assert.Len(t, limiter.metrics, 2)
+
+	// http.requests is not received for a window, http.responses is.
+	now = now.Add(40 * time.Second)
+	limiter.limit(context.Background(), "http.responses", createRequestsMetric("1"))
+	assert.Len(t, limiter.metrics, 1)
+	assert.Contains(t, limiter.metrics, "http.responses")
+}
+
+func TestCardinalityLimitMergesDataPoints(t *testing.T) {
+	settings := componenttest.NewNopTelemetrySettings()
+	limiter := newCardinalityLimiter(CardinalityConfig{MaxResourcePaths: 1, Window: time.Minute, Action: cardinalityActionCollapse},
+		newTestAttributeMapper(t, MappingConfig{}), settings.Logger, newTestTelemetryBuilder(t, settings))
+
+	metric := pmetric.NewMetric()
+	histogram := metric.SetEmptyHistogram()
+	for i, user := range []string{"1", "2", "3"} {
+		dp := histogram.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("user.id", user)
+		dp.ExplicitBounds().FromRaw([]float64{10})
+		dp.BucketCounts().FromRaw([]uint64{uint64(i), 1})
+		dp.SetCount(uint64(i) + 1)
+		dp.SetSum(float64(5*i + 20))
+	}
+
+	limited := limiter.limit(context.Background(), "http.duration", metric)
+	dps := limited.Histogram().DataPoints()
+	require.Equal(t, 2, dps.Len())
+	overflow := dps.At(1)
+	assert.Equal(t, []uint64{3, 2}, overflow.BucketCounts().AsRaw())
+	assert.EqualValues(t, 5, overflow.Count())
+	assert.EqualValues(t, 55, overflow.Sum())
+}
+
+func TestCardinalityLimitAfterCumulativeConversion(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.CumulativeSums.Conversion = conversionDelta
+	cfg.Cardinality.MaxResourcePaths = 1
+	cfg.Cardinality.Window = time.Hour
+	producer := newTestProducer(t, cfg)
+
+	// Each device is its own series, even once the device attribute is collapsed.
+	createSums := func(seconds int, values ...int64) pmetric.Metrics {
+		md := createCumulativeSum("system.network.io", cumulativeStart, seconds, values[0])
+		dps := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints()
+		for i, value := range values[1:] {
+			dp := dps.AppendEmpty()
+			dps.At(0).CopyTo(dp)
+			dp.Attributes().PutStr("device", fmt.Sprintf("eth%d", i+1))
+			dp.SetIntValue(value)
+		}
+		return md
+	}
+	require.NoError(t, exportRequest(producer.convertMetrics(context.Background(), createSums(0, 100, 1000, 5000))))
+	require.NoError(t, exportRequest(producer.convertMetrics(context.Background(), createSums(10, 110, 1020, 5030))))
+
+	payloads := mid.received("/metrics")
+	require.Len(t, payloads, 1)
+	var metrics []ServiceNowMetric
+	require.NoError(t, json.Unmarshal(payloads[0], &metrics))
+	values := make(map[string]float64)
+	for _, m := range metrics {
+		assert.NotContains(t, values, m.ResourcePath, "duplicate resource_path")
+		values[m.ResourcePath] = m.Value
+	}
+	assert.Equal(t, map[string]float64{
+		"system.network.io;device=eth0":         10,
+		"system.network.io;device=__overflow__": 50,
+	}, values)
+}
+
+func TestCardinalityLimitDisabled(t *testing.T) {
+	mid := newMidServerMock(t)
+	producer := newTestProducer(t, mid.config())
+
+	users := make([]string, 100)
+	for i := range users {
+		users[i] = fmt.Sprint(i)
+	}
+	metric := createRequestsMetric(users...)
+	assert.Len(t, resourcePaths(t, producer.cardinality.limit(context.Background(), "http.requests", metric)), 100)
+}
+
+func TestCardinalityConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Cardinality.MaxResourcePaths = 1000
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.Cardinality.Window = 0
+	assert.EqualError(t, component.ValidateConfig(cfg), "cardinality: window must be greater than 0")
+
+	cfg.Cardinality.Window = time.Hour
+	cfg.Cardinality.Action = "sample"
+	assert.EqualError(t, component.ValidateConfig(cfg), `cardinality: unsupported action "sample", must be collapse or drop`)
+}
@@ -2,133 +2,276 @@ package servicenowexporter
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
+
+	"github.com/lightstep/sn-collector/collector/servicenowexporter/internal/metadata"
+)
+
+const (
+	// Values of the endpoint attribute of the internal metrics.
+	endpointEvents  = "events"
+	endpointLogs    = "logs"
+	endpointMetrics = "metrics"
+
+	// Values of the outcome attribute of the requests metric.
+	outcomeSuccess = "success"
+	outcomeFailure = "failure"
 )
 
 type midClient struct {
 	config     *Config
 	httpClient *http.Client
+	auth       requestAuthenticator
 	logger     *zap.Logger
+	telemetry  *metadata.TelemetryBuilder
+	debug      *payloadDebugger
 }
 
-func newMidClient(config *Config, l *zap.Logger) *midClient {
-	return &midClient{
-		config: config,
-		logger: l,
-		httpClient: &http.Client{
-			Timeout: config.TimeoutSettings.Timeout,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{
-					InsecureSkipVerify: config.InsecureSkipVerify,
-				},
-			},
-		},
+func newMidClient(ctx context.Context, host component.Host, settings component.TelemetrySettings, telemetry *metadata.TelemetryBuilder, config *Config) (*midClient, error) {
+	clientConfig := config.ClientConfig
+	if config.InsecureSkipVerify {
+		clientConfig.TLSSetting.InsecureSkipVerify = true
+	}
+
+	httpClient, err := clientConfig.ToClient(ctx, host, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	// OAuth2 token requests share the TLS and proxy settings, but not the
+	// compression, headers or auth extension of the payload requests.
+	tokenClientConfig := clientConfig
+	tokenClientConfig.Compression = ""
+	tokenClientConfig.Headers = nil
+	tokenClientConfig.Auth = nil
+	tokenClient, err := tokenClientConfig.ToClient(ctx, host, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	debug, err := newPayloadDebugger(config.Debug, config.Mapping.AdditionalInfoKeySeparator, settings.Logger)
+	if err != nil {
+		return nil, err
 	}
+
+	return &midClient{
+		config:     config,
+		logger:     settings.Logger,
+		telemetry:  telemetry,
+		debug:      debug,
+		httpClient: httpClient,
+		auth:       newRequestAuthenticator(config, tokenClient),
+	}, nil
+}
+
+// statusError is the error of a non-2xx response of a ServiceNow endpoint.
+type statusError struct {
+	statusCode int
+	body       string
+	// retryAfter is the delay requested by the Retry-After header, zero when not set
+	retryAfter time.Duration
 }
 
+func (e *statusError) Error() string {
+	return fmt.Sprintf("ServiceNow API returned non-2xx status code: %d (%s)", e.statusCode, e.body)
+}
+
+// handleNon200Response classifies the error so the exporterhelper retry logic can act on it:
+// throttling and server errors are retried, honoring the Retry-After header when present,
+// while any other client error is permanent since the same payload would be rejected again.
 func handleNon200Response(res *http.Response) error {
 	bodyBytes, err := io.ReadAll(res.Body)
 	if err != nil {
 		return err
 	}
 
-	return fmt.Errorf("ServiceNow API returned non-200 status code: %d (%s)", res.StatusCode, string(bodyBytes))
+	statusErr := &statusError{statusCode: res.StatusCode, body: string(bodyBytes)}
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		if delay, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			statusErr.retryAfter = delay
+			return exporterhelper.NewThrottleRetry(statusErr, delay)
+		}
+		return statusErr
+	case res.StatusCode == http.StatusRequestTimeout:
+		return statusErr
+	case res.StatusCode >= 400:
+		return consumererror.NewPermanent(statusErr)
+	}
+	return statusErr
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
 }
 
 func (c *midClient) Close() {
 	c.httpClient.CloseIdleConnections()
+	c.debug.Close()
 }
 
-func (c *midClient) sendEvents(events []ServiceNowEvent) error {
-	url := c.config.PushEventsURL
+// eventsBatchOverhead is the size of the {"records":[]} envelope around the batched events.
+var eventsBatchOverhead = len(`{"records":[]}`)
 
-	for _, e := range events {
-		c.logger.Info("Sending event to ServiceNow", zap.String("url", url), zap.Any("event", e))
-		body, err := json.Marshal(e)
-		if err != nil {
-			return err
-		}
-		r, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-		if err != nil {
-			return err
+// sendEvents sends the events in batches, returning how many of them were handled before a
+// batch failed so callers can retry only the remaining ones. A batch rejected with a permanent
+// error is dropped and the next batches are still sent: the events are then all handled and the
+// returned error is the permanent error of the rejected batches.
+func (c *midClient) sendEvents(ctx context.Context, events []ServiceNowEvent) (int, error) {
+	batches, err := batchEvents(events, c.config.EventsBatch)
+	if err != nil {
+		return 0, consumererror.NewPermanent(err)
+	}
+
+	sent := 0
+	var rejected error
+	for _, batch := range batches {
+		if err := c.sendEventBatch(ctx, batch); err != nil {
+			if !consumererror.IsPermanent(err) {
+				return sent, err
+			}
+			c.logger.Error("Dropping events rejected by ServiceNow", zap.Int("eventCount", len(batch.Records)), zap.Error(err))
+			c.telemetry.ServicenowExporterItemsDropped.Add(ctx, int64(len(batch.Records)), metric.WithAttributes(attribute.String("endpoint", endpointEvents)))
+			rejected = errors.Join(rejected, err)
 		}
-		r.Header.Set("Content-Type", "application/json")
-		r.SetBasicAuth(c.config.Username, string(c.config.Password))
+		sent += len(batch.Records)
+	}
+
+	return sent, rejected
+}
+
+// batchEvents serializes the events and groups them so each batch stays within
+// the configured number of events and payload size.
+func batchEvents(events []ServiceNowEvent, cfg EventsBatchConfig) ([]ServiceNowEventBatch, error) {
+	batches := make([]ServiceNowEventBatch, 0)
+	current := ServiceNowEventBatch{}
+	currentSize := eventsBatchOverhead
 
-		res, err := c.httpClient.Do(r)
+	for _, e := range events {
+		record, err := json.Marshal(e)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		defer res.Body.Close()
 
-		if res.StatusCode != 200 {
-			handleNon200Response(res)
+		// Records after the first one need a separating comma.
+		recordSize := len(record)
+		if len(current.Records) > 0 {
+			recordSize++
 		}
-	}
-
-	return nil
-}
 
-func (c *midClient) sendLogs(payload []ServiceNowLog) error {
-	url := c.config.PushLogsURL
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-	r, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	r.Header.Set("Content-Type", "application/json")
+		if len(current.Records) > 0 && (len(current.Records) >= cfg.MaxSize || currentSize+recordSize > cfg.MaxPayloadBytes) {
+			batches = append(batches, current)
+			current = ServiceNowEventBatch{}
+			currentSize = eventsBatchOverhead
+			recordSize = len(record)
+		}
 
-	if len(c.config.Username) > 0 {
-		r.SetBasicAuth(c.config.Username, string(c.config.Password))
-	} else if len(c.config.ApiKey) > 0 {
-		r.Header.Set("Authorization", "key "+string(c.config.ApiKey))
+		current.Records = append(current.Records, record)
+		currentSize += recordSize
 	}
 
-	if err != nil {
-		return err
+	if len(current.Records) > 0 {
+		batches = append(batches, current)
 	}
+	return batches, nil
+}
 
-	res, err := c.httpClient.Do(r)
-	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
+func (c *midClient) sendEventBatch(ctx context.Context, batch ServiceNowEventBatch) error {
+	url := c.config.PushEventsURL
+	c.logger.Debug("Sending events to ServiceNow", zap.String("url", url), zap.Int("eventCount", len(batch.Records)))
+	return c.postJSON(ctx, endpointEvents, url, batch, len(batch.Records))
+}
 
-	if res.StatusCode != 200 {
-		return handleNon200Response(res)
-	}
+func (c *midClient) sendLogs(ctx context.Context, payload []ServiceNowLog) error {
+	return c.postJSON(ctx, endpointLogs, c.config.PushLogsURL, payload, len(payload))
+}
 
-	return nil
+// sendMetrics sends the metrics in a single request. Unlike the events, there is no partial
+// rejection: all the metrics are retried after a transient error, and all are dropped when the
+// MID Server rejects the request.
+func (c *midClient) sendMetrics(ctx context.Context, payload []ServiceNowMetric) error {
+	return c.postJSON(ctx, endpointMetrics, c.config.PushMetricsURL, payload, len(payload))
 }
 
-func (c *midClient) sendMetrics(payload []ServiceNowMetric) error {
-	url := c.config.PushMetricsURL
+// postJSON sends the payload holding items events, logs or metrics to one of the ServiceNow
+// endpoints, authenticating the request the same way for all of them.
+func (c *midClient) postJSON(ctx context.Context, endpoint string, url string, payload any, items int) error {
 	body, err := json.Marshal(payload)
+	if err != nil {
+		return consumererror.NewPermanent(err)
+	}
+	r, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 	if err != nil {
 		return err
 	}
-	r, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	r.Header.Set("Content-Type", "application/json")
-	r.SetBasicAuth(c.config.Username, string(c.config.Password))
-	if err != nil {
+	if err := c.auth.authenticate(r); err != nil {
 		return err
 	}
+	c.debug.sample(endpoint, url, body)
 
+	endpointAttr := metric.WithAttributes(attribute.String("endpoint", endpoint))
+	start := time.Now()
 	res, err := c.httpClient.Do(r)
+	c.telemetry.ServicenowExporterRequestDuration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), endpointAttr)
+	c.telemetry.ServicenowExporterPayloadBytes.Add(ctx, int64(len(body)), endpointAttr)
 	if err != nil {
+		c.recordRequest(ctx, endpoint, outcomeFailure)
 		return err
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
+	c.telemetry.ServicenowExporterHttpStatus.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("endpoint", endpoint),
+		attribute.Int("status_code", res.StatusCode),
+	))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		c.recordRequest(ctx, endpoint, outcomeFailure)
+		if res.StatusCode >= 400 && res.StatusCode < 500 {
+			c.debug.dumpRejected(endpoint, url, res.StatusCode, body)
+		}
 		return handleNon200Response(res)
 	}
 
+	c.recordRequest(ctx, endpoint, outcomeSuccess)
+	c.telemetry.ServicenowExporterItemsSent.Add(ctx, int64(items), endpointAttr)
 	return nil
 }
+
+func (c *midClient) recordRequest(ctx context.Context, endpoint string, outcome string) {
+	c.telemetry.ServicenowExporterRequests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("endpoint", endpoint),
+		attribute.String("outcome", outcome),
+	))
+}
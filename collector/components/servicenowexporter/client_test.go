@@ -0,0 +1,283 @@
+package servicenowexporter
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configcompression"
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func createTestEvents(n int) []ServiceNowEvent {
+	events := make([]ServiceNowEvent, n)
+	for i := range events {
+		events[i] = ServiceNowEvent{
+			Type:        "test",
+			Description: fmt.Sprintf("event %d", i),
+			Severity:    severityInfo,
+			Source:      midSource,
+		}
+	}
+	return events
+}
+
+func TestBatchEventsMaxSize(t *testing.T) {
+	batches, err := batchEvents(createTestEvents(5), EventsBatchConfig{MaxSize: 2, MaxPayloadBytes: 1024 * 1024})
+	require.NoError(t, err)
+
+	sizes := make([]int, 0, len(batches))
+	for _, b := range batches {
+		sizes = append(sizes, len(b.Records))
+	}
+	assert.Equal(t, []int{2, 2, 1}, sizes)
+}
+
+func TestBatchEventsMaxPayloadBytes(t *testing.T) {
+	events := createTestEvents(4)
+	record, err := json.Marshal(events[0])
+	require.NoError(t, err)
+
+	// Room for exactly two records per request.
+	maxBytes := eventsBatchOverhead + 2*len(record) + 1
+	batches, err := batchEvents(events, EventsBatchConfig{MaxSize: 100, MaxPayloadBytes: maxBytes})
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+
+	for _, b := range batches {
+		body, err := json.Marshal(b)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(body), maxBytes)
+	}
+
+	// An event larger than the limit is still sent on its own.
+	batches, err = batchEvents(events, EventsBatchConfig{MaxSize: 100, MaxPayloadBytes: 1})
+	require.NoError(t, err)
+	assert.Len(t, batches, 4)
+}
+
+func TestSendEventsBatches(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.EventsBatch.MaxSize = 3
+	client := newTestMidClient(t, cfg)
+
+	sent, err := client.sendEvents(context.Background(), createTestEvents(7))
+	require.NoError(t, err)
+	assert.Equal(t, 7, sent)
+
+	payloads := mid.received("/events")
+	require.Len(t, payloads, 3)
+	var descriptions []string
+	for _, p := range payloads {
+		for _, e := range decodeEventBatch(t, p) {
+			descriptions = append(descriptions, e.Description)
+		}
+	}
+	assert.Len(t, descriptions, 7)
+	assert.Equal(t, "event 0", descriptions[0])
+	assert.Equal(t, "event 6", descriptions[6])
+}
+
+func TestSendEventsAccepts2xx(t *testing.T) {
+	statuses := []int{http.StatusCreated, http.StatusAccepted, http.StatusNoContent}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(statuses[requests.Add(1)-1])
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushEventsURL = server.URL
+	cfg.EventsBatch.MaxSize = 1
+	client := newTestMidClient(t, cfg)
+
+	sent, err := client.sendEvents(context.Background(), createTestEvents(3))
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+}
+
+func TestHandleNon200Response(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		permanent  bool
+		throttled  bool
+	}{
+		{name: "bad request", status: http.StatusBadRequest, permanent: true},
+		{name: "unauthorized", status: http.StatusUnauthorized, permanent: true},
+		{name: "request timeout", status: http.StatusRequestTimeout},
+		{name: "too many requests", status: http.StatusTooManyRequests},
+		{name: "too many requests with retry after", status: http.StatusTooManyRequests, retryAfter: "30", throttled: true},
+		{name: "internal server error", status: http.StatusInternalServerError},
+		{name: "unavailable with retry after date", status: http.StatusServiceUnavailable, retryAfter: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), throttled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("rejected")),
+			}
+			if tt.retryAfter != "" {
+				res.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			err := handleNon200Response(res)
+			var statusErr *statusError
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, tt.status, statusErr.statusCode)
+			assert.Equal(t, "rejected", statusErr.body)
+			assert.Equal(t, tt.permanent, consumererror.IsPermanent(err))
+			assert.Equal(t, tt.throttled, statusErr.retryAfter > 0)
+		})
+	}
+}
+
+func TestSendEventsPartialFailure(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushEventsURL = server.URL
+	cfg.EventsBatch.MaxSize = 2
+	client := newTestMidClient(t, cfg)
+
+	sent, err := client.sendEvents(context.Background(), createTestEvents(5))
+	require.Error(t, err)
+	assert.False(t, consumererror.IsPermanent(err))
+	assert.Equal(t, 2, sent)
+}
+
+func TestSendEventsRejectedBatch(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushEventsURL = server.URL
+	cfg.EventsBatch.MaxSize = 2
+	client := newTestMidClient(t, cfg)
+
+	// The rejected batch is dropped and the next one is still sent.
+	sent, err := client.sendEvents(context.Background(), createTestEvents(5))
+	assert.True(t, consumererror.IsPermanent(err))
+	assert.Equal(t, 5, sent)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClientConfigHeadersAndCompression(t *testing.T) {
+	var (
+		gotHeader   string
+		gotEncoding string
+		gotBody     []ServiceNowMetric
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("X-Custom")
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gz, err := gzip.NewReader(r.Body)
+		if assert.NoError(t, err) {
+			assert.NoError(t, json.NewDecoder(gz).Decode(&gotBody))
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushMetricsURL = server.URL
+	cfg.Headers = map[string]configopaque.String{"X-Custom": "value"}
+	cfg.Compression = configcompression.TypeGzip
+	client := newTestMidClient(t, cfg)
+
+	require.NoError(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "test", Value: 1}}))
+	assert.Equal(t, "value", gotHeader)
+	assert.Equal(t, "gzip", gotEncoding)
+	assert.Equal(t, []ServiceNowMetric{{MetricType: "test", Value: 1}}, gotBody)
+}
+
+// sumValue returns the value of the data point of a counter with the given attributes.
+func sumValue(t *testing.T, m metricdata.Metrics, attrs ...attribute.KeyValue) int64 {
+	set := attribute.NewSet(attrs...)
+	for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		if dp.Attributes.Equals(&set) {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+func TestExportTelemetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushEventsURL = server.URL + "/events"
+	cfg.PushMetricsURL = server.URL + "/metrics"
+	settings, reader := newTestTelemetrySettings()
+	producer, err := newServiceNowProducer(settings, cfg)
+	require.NoError(t, err)
+	require.NoError(t, producer.start(context.Background(), componenttest.NewNopHost()))
+	defer producer.Close(context.Background())
+
+	req := &serviceNowRequest{
+		producer: producer,
+		Events:   createTestEvents(3),
+		Metrics:  []ServiceNowMetric{{MetricType: "cpu", Value: 1}, {MetricType: "memory", Value: 2}},
+	}
+	err = req.Export(context.Background())
+	require.Error(t, err)
+	assert.True(t, consumererror.IsPermanent(err))
+
+	events := attribute.String("endpoint", endpointEvents)
+	metrics := attribute.String("endpoint", endpointMetrics)
+
+	requests := collectMetric(t, reader, "servicenow_exporter_requests")
+	assert.EqualValues(t, 1, sumValue(t, requests, events, attribute.String("outcome", outcomeSuccess)))
+	assert.EqualValues(t, 1, sumValue(t, requests, metrics, attribute.String("outcome", outcomeFailure)))
+
+	statuses := collectMetric(t, reader, "servicenow_exporter_http_status")
+	assert.EqualValues(t, 1, sumValue(t, statuses, events, attribute.Int("status_code", http.StatusOK)))
+	assert.EqualValues(t, 1, sumValue(t, statuses, metrics, attribute.Int("status_code", http.StatusBadRequest)))
+
+	assert.EqualValues(t, 3, sumValue(t, collectMetric(t, reader, "servicenow_exporter_items_sent"), events))
+	assert.EqualValues(t, 0, sumValue(t, collectMetric(t, reader, "servicenow_exporter_items_sent"), metrics))
+	assert.EqualValues(t, 2, sumValue(t, collectMetric(t, reader, "servicenow_exporter_items_dropped"), metrics))
+	assert.Positive(t, sumValue(t, collectMetric(t, reader, "servicenow_exporter_payload_bytes"), metrics))
+
+	durations := collectMetric(t, reader, "servicenow_exporter_request_duration").Data.(metricdata.Histogram[float64])
+	assert.Len(t, durations.DataPoints, 2)
+}
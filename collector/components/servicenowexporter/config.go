@@ -1,16 +1,27 @@
 package servicenowexporter
 
 import (
+	"errors"
+	"time"
+
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/config/configopaque"
 	"go.opentelemetry.io/collector/config/configretry"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
 )
 
+// Config defines configuration for the ServiceNow exporter.
+//
+// The same authentication is used for all the endpoints, picking the first configured of:
+// a collector auth extension (auth.authenticator), oauth2, bearer_token, username/password
+// (basic auth) and api_key.
 type Config struct {
-	exporterhelper.TimeoutSettings `mapstructure:",squash"` // squash ensures fields are correctly decoded in embedded struct.
-	exporterhelper.QueueSettings   `mapstructure:"sending_queue"`
-	configretry.BackOffConfig      `mapstructure:"retry_on_failure"`
+	// ClientConfig is shared by the requests sent to all the endpoints below (TLS, proxy, headers,
+	// compression, timeout, auth extension, etc). Its endpoint field is not used.
+	confighttp.ClientConfig      `mapstructure:",squash"` // squash ensures fields are correctly decoded in embedded struct.
+	exporterhelper.QueueSettings `mapstructure:"sending_queue"`
+	configretry.BackOffConfig    `mapstructure:"retry_on_failure"`
 
 	// PushMetricsURL is the full url of the ServiceNow instance to send push metrics to. Ex: http://127.0.0.1:8090/api/mid/sa/metrics
 	PushMetricsURL string `mapstructure:"instance_metrics_url"`
@@ -21,26 +32,134 @@ type Config struct {
 	// PushEventsURL is the full url of the ServiceNow instance to send push events to. Ex: http://127.0.0.1:8090/api/sn_em_connector/em/inbound_event?source=snotel
 	PushEventsURL string `mapstructure:"instance_events_url"`
 
-	// ApiKey is used to set an Authorization header with a bearer token
+	// ApiKey is used to set an Authorization header with a ServiceNow API key ("key <api_key>")
 	ApiKey configopaque.String `mapstructure:"api_key"`
 
-	// InsecureSkipVerify disables TLS certificate verification
+	// BearerToken is used to set an Authorization header with a bearer token
+	BearerToken configopaque.String `mapstructure:"bearer_token"`
+
+	// OAuth2 is used to get access tokens from a ServiceNow OAuth2 application
+	OAuth2 *OAuth2Config `mapstructure:"oauth2"`
+
+	// InsecureSkipVerify disables TLS certificate verification.
+	// Deprecated: use tls.insecure_skip_verify instead.
 	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
 
 	// Username is used to optionally specify the basic auth username
 	Username string `mapstructure:"username"`
 	// Password is used to optionally specify the basic auth password
 	Password configopaque.String `mapstructure:"password"`
+
+	// Events configures the message_key, event_class and metric_name fields of events, and the Clear events
+	Events EventsConfig `mapstructure:"events"`
+
+	// KubernetesEvents configures the mapping of the Kubernetes events collected as logs
+	KubernetesEvents KubernetesEventsConfig `mapstructure:"k8s_events"`
+
+	// EventsBatch configures how events are grouped into requests to the inbound_event API
+	EventsBatch EventsBatchConfig `mapstructure:"events_batch"`
+
+	// SeverityMapping overrides how log severities are mapped to ServiceNow severities
+	SeverityMapping SeverityMappingConfig `mapstructure:"severity_mapping"`
+
+	// Mapping configures how the node, resource and resource_path fields are derived from attributes
+	Mapping MappingConfig `mapstructure:"mapping"`
+
+	// MetricsFilter selects and renames the metrics sent to ServiceNow
+	MetricsFilter MetricsFilterConfig `mapstructure:"metrics_filter"`
+
+	// Cardinality limits the number of distinct resource paths sent per metric
+	Cardinality CardinalityConfig `mapstructure:"cardinality"`
+
+	// CumulativeSums configures the conversion of cumulative sum metrics to deltas or rates
+	CumulativeSums CumulativeSumsConfig `mapstructure:"cumulative_sums"`
+
+	// Histograms configures how histogram and exponential histogram metrics are sent
+	Histograms HistogramsConfig `mapstructure:"histograms"`
+
+	// Rules send events when metrics breach thresholds
+	Rules []ThresholdRule `mapstructure:"rules"`
+
+	// Traces configures how spans are converted to ServiceNow events and metrics
+	Traces TracesConfig `mapstructure:"traces"`
+
+	// Debug configures the sampling of the payloads logged and the dump of rejected payloads
+	Debug DebugConfig `mapstructure:"debug"`
+}
+
+// TracesConfig defines how spans are mapped to ServiceNow events and metrics.
+// Spans with an error status are always sent as events.
+type TracesConfig struct {
+	// SendREDMetrics enables sending rate, errors and duration metrics per service and operation,
+	// the rates being per second over the time window of the spans of each batch
+	SendREDMetrics bool `mapstructure:"send_red_metrics"`
+}
+
+// EventsBatchConfig defines the limits of a single {"records": [...]} request sent to the inbound_event API.
+type EventsBatchConfig struct {
+	// MaxSize is the maximum number of events sent in a single request
+	MaxSize int `mapstructure:"max_size"`
+
+	// MaxPayloadBytes is the maximum size of the JSON body of a single request. An event larger
+	// than this limit is still sent, alone in its own request.
+	MaxPayloadBytes int `mapstructure:"max_payload_bytes"`
+}
+
+func (cfg *Config) Validate() error {
+	return validateRules(cfg.Rules)
+}
+
+func (cfg *EventsBatchConfig) Validate() error {
+	if cfg.MaxSize <= 0 {
+		return errors.New("events_batch: max_size must be greater than 0")
+	}
+	if cfg.MaxPayloadBytes <= 0 {
+		return errors.New("events_batch: max_payload_bytes must be greater than 0")
+	}
+	return nil
 }
 
 func createDefaultConfig() component.Config {
+	clientConfig := confighttp.NewDefaultClientConfig()
+	clientConfig.Timeout = 5 * time.Second
+
 	return &Config{
+		ClientConfig:       clientConfig,
 		PushMetricsURL:     "http://localhost:8090/api/mid/sa/metrics",
 		PushLogsURL:        "",
 		PushEventsURL:      "http://localhost:8090/api/sn_em_connector/em/inbound_event?source=snotel",
 		InsecureSkipVerify: false,
-		TimeoutSettings:    exporterhelper.NewDefaultTimeoutSettings(),
 		BackOffConfig:      configretry.NewDefaultBackOffConfig(),
 		QueueSettings:      exporterhelper.NewDefaultQueueSettings(),
+		EventsBatch: EventsBatchConfig{
+			MaxSize:         100,
+			MaxPayloadBytes: 1024 * 1024,
+		},
+		Events: EventsConfig{
+			OpenAlertsTTL: 24 * time.Hour,
+		},
+		Cardinality: CardinalityConfig{
+			Window: time.Hour,
+			Action: cardinalityActionCollapse,
+		},
+		CumulativeSums: CumulativeSumsConfig{
+			Conversion:   conversionNone,
+			MaxStaleness: time.Hour,
+		},
+		Histograms: HistogramsConfig{
+			Strategy:    histogramStrategyBuckets,
+			Percentiles: []float64{50, 90, 99},
+		},
+		Mapping: MappingConfig{
+			NodeAttributes:             []string{"host.name"},
+			KeySeparator:               ".",
+			AdditionalInfoKeySeparator: "_",
+		},
+		Debug: DebugConfig{
+			PayloadSampling: PayloadSamplingConfig{
+				MaxPayloads: 1,
+				Interval:    time.Minute,
+			},
+		},
 	}
 }
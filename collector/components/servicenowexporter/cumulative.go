@@ -0,0 +1,196 @@
+package servicenowexporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	// conversionNone sends the cumulative values as they are.
+	conversionNone = "none"
+	// conversionDelta sends the difference with the previous value of the series.
+	conversionDelta = "delta"
+	// conversionRate sends the difference with the previous value of the series per second.
+	conversionRate = "rate"
+)
+
+// CumulativeSumsConfig defines how sum metrics with a cumulative temporality are converted.
+// Delta and rate conversions need two data points of a series, so its first one is not sent.
+type CumulativeSumsConfig struct {
+	// Conversion is one of none (default), delta or rate
+	Conversion string `mapstructure:"conversion"`
+
+	// Rules override the conversion of the metrics whose name matches, the first matching rule is used
+	Rules []CumulativeSumRule `mapstructure:"rules"`
+
+	// MaxStaleness is how long the last value of a series is kept when no new data points are received
+	MaxStaleness time.Duration `mapstructure:"max_staleness"`
+}
+
+// CumulativeSumRule sets the conversion of the metrics whose name matches a glob pattern. Ex: system.network.*
+type CumulativeSumRule struct {
+	Metric     string `mapstructure:"metric"`
+	Conversion string `mapstructure:"conversion"`
+}
+
+func (cfg *CumulativeSumsConfig) Validate() error {
+	if err := validateConversion(cfg.Conversion); err != nil {
+		return err
+	}
+	for _, rule := range cfg.Rules {
+		if _, err := path.Match(rule.Metric, ""); err != nil || rule.Metric == "" {
+			return fmt.Errorf("cumulative_sums: invalid metric pattern %q", rule.Metric)
+		}
+		if err := validateConversion(rule.Conversion); err != nil {
+			return err
+		}
+	}
+	if cfg.MaxStaleness <= 0 {
+		return errors.New("cumulative_sums: max_staleness must be greater than 0")
+	}
+	return nil
+}
+
+func validateConversion(conversion string) error {
+	switch conversion {
+	case conversionNone, conversionDelta, conversionRate:
+		return nil
+	}
+	return fmt.Errorf("cumulative_sums: unsupported conversion %q, must be %s, %s or %s",
+		conversion, conversionNone, conversionDelta, conversionRate)
+}
+
+// seriesState is the last data point received for a series.
+type seriesState struct {
+	start    pcommon.Timestamp
+	time     pcommon.Timestamp
+	value    float64
+	lastSeen time.Time
+}
+
+// cumulativeConverter keeps the last value of each cumulative series to convert the next one.
+type cumulativeConverter struct {
+	config CumulativeSumsConfig
+
+	mu        sync.Mutex
+	series    map[string]*seriesState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newCumulativeConverter(cfg CumulativeSumsConfig) *cumulativeConverter {
+	return &cumulativeConverter{
+		config: cfg,
+		series: make(map[string]*seriesState),
+		now:    time.Now,
+	}
+}
+
+// conversion returns the conversion configured for a metric.
+func (c *cumulativeConverter) conversion(metricName string) string {
+	for _, rule := range c.config.Rules {
+		if ok, _ := path.Match(rule.Metric, metricName); ok {
+			return rule.Conversion
+		}
+	}
+	if c.config.Conversion == "" {
+		return conversionNone
+	}
+	return c.config.Conversion
+}
+
+// convertMetric returns a copy of a cumulative sum with the values of its data points converted
+// to deltas or rates per the conversion configured for the metric, the data points that cannot be
+// converted being removed. The conversion runs on the original attributes, before the cardinality
+// limit collapses any of them. Other metrics are returned unchanged.
+func (c *cumulativeConverter) convertMetric(metricName string, rAttrs pcommon.Map, m pmetric.Metric) pmetric.Metric {
+	if m.Type() != pmetric.MetricTypeSum || m.Sum().AggregationTemporality() != pmetric.AggregationTemporalityCumulative {
+		return m
+	}
+	conversion := c.conversion(metricName)
+	if conversion == conversionNone {
+		return m
+	}
+
+	converted := pmetric.NewMetric()
+	m.CopyTo(converted)
+	sum := converted.Sum()
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	sum.DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+		val, ok := numberValue(dp)
+		if !ok {
+			return true
+		}
+		val, ok = c.convert(conversion, seriesKey(metricName, rAttrs, dp.Attributes()), sum.IsMonotonic(), dp, val)
+		if !ok {
+			return true
+		}
+		dp.SetDoubleValue(val)
+		return false
+	})
+	return converted
+}
+
+// convert returns the delta or rate of a data point since the previous one of the same series,
+// false when there is none or the data point is out of order. A lower value of a monotonic sum
+// or a new start timestamp resets the series: the value is then the delta since the start.
+func (c *cumulativeConverter) convert(conversion string, key string, monotonic bool, dp pmetric.NumberDataPoint, value float64) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	prev, ok := c.series[key]
+	if !ok {
+		c.series[key] = &seriesState{start: dp.StartTimestamp(), time: dp.Timestamp(), value: value, lastSeen: now}
+		return 0, false
+	}
+	if dp.Timestamp() <= prev.time {
+		return 0, false
+	}
+
+	delta := value - prev.value
+	since := prev.time
+	restarted := dp.StartTimestamp() != 0 && dp.StartTimestamp() != prev.start
+	if restarted || (monotonic && delta < 0) {
+		delta = value
+		// Without a new start timestamp, the reset happened at some point since the previous data point.
+		if restarted && dp.StartTimestamp() > prev.time && dp.StartTimestamp() < dp.Timestamp() {
+			since = dp.StartTimestamp()
+		}
+	}
+	*prev = seriesState{start: dp.StartTimestamp(), time: dp.Timestamp(), value: value, lastSeen: now}
+
+	if conversion == conversionRate {
+		return delta / dp.Timestamp().AsTime().Sub(since.AsTime()).Seconds(), true
+	}
+	return delta, true
+}
+
+// sweep forgets the series not seen for max_staleness, at most once per max_staleness.
+func (c *cumulativeConverter) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.config.MaxStaleness {
+		return
+	}
+	c.lastSweep = now
+	for key, s := range c.series {
+		if now.Sub(s.lastSeen) >= c.config.MaxStaleness {
+			delete(c.series, key)
+		}
+	}
+}
+
+// seriesKey identifies a series by metric name, resource attributes and data point attributes.
+func seriesKey(metricName string, rAttrs pcommon.Map, attrs pcommon.Map) string {
+	// json sorts the map keys, so the key doesn't depend on the attributes order.
+	key, _ := json.Marshal([]any{metricName, rAttrs.AsRaw(), attrs.AsRaw()})
+	return string(key)
+}
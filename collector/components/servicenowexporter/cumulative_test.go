@@ -0,0 +1,114 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+var cumulativeStart = time.Unix(1700000000, 0)
+
+// createCumulativeSum returns a monotonic cumulative sum data point taken after seconds.
+func createCumulativeSum(name string, start time.Time, seconds int, value int64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("host.name", "host-1")
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName(name)
+	sum := metric.SetEmptySum()
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sum.SetIsMonotonic(true)
+	dp := sum.DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(cumulativeStart.Add(time.Duration(seconds) * time.Second)))
+	dp.Attributes().PutStr("device", "eth0")
+	dp.SetIntValue(value)
+	return md
+}
+
+func TestCumulativeSumConversion(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.CumulativeSums.Conversion = conversionDelta
+	cfg.CumulativeSums.Rules = []CumulativeSumRule{{Metric: "system.network.*", Conversion: conversionRate}}
+	producer := newTestProducer(t, cfg)
+
+	points := []struct {
+		start   time.Time
+		seconds int
+		value   int64
+	}{
+		{cumulativeStart, 0, 100},
+		{cumulativeStart, 10, 150},
+		// counter reset, without a new start time
+		{cumulativeStart, 20, 30},
+		// process restart, with a new start time
+		{cumulativeStart.Add(25 * time.Second), 30, 20},
+	}
+	for _, p := range points {
+		for _, name := range []string{"http.requests", "system.network.io"} {
+			require.NoError(t, exportRequest(producer.convertMetrics(context.Background(), createCumulativeSum(name, p.start, p.seconds, p.value))))
+		}
+	}
+
+	values := make(map[string][]float64)
+	for _, payload := range mid.received("/metrics") {
+		var metrics []ServiceNowMetric
+		require.NoError(t, json.Unmarshal(payload, &metrics))
+		for _, m := range metrics {
+			values[m.MetricType] = append(values[m.MetricType], m.Value)
+		}
+	}
+	assert.Equal(t, []float64{50, 30, 20}, values["http.requests"])
+	assert.Equal(t, []float64{5, 3, 4}, values["system.network.io"])
+}
+
+func TestCumulativeSumsNotConvertedByDefault(t *testing.T) {
+	mid := newMidServerMock(t)
+	producer := newTestProducer(t, mid.config())
+
+	require.NoError(t, exportRequest(producer.convertMetrics(context.Background(), createCumulativeSum("http.requests", cumulativeStart, 0, 100))))
+
+	payloads := mid.received("/metrics")
+	require.Len(t, payloads, 1)
+	var metrics []ServiceNowMetric
+	require.NoError(t, json.Unmarshal(payloads[0], &metrics))
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 100.0, metrics[0].Value)
+}
+
+func TestCumulativeConverterForgetsStaleSeries(t *testing.T) {
+	converter := newCumulativeConverter(CumulativeSumsConfig{MaxStaleness: time.Minute})
+	now := cumulativeStart
+	converter.now = func() time.Time { return now }
+
+	dp := pmetric.NewNumberDataPoint()
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	_, ok := converter.convert(conversionDelta, "series", true, dp, 1)
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Minute)
+	dp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	_, ok = converter.convert(conversionDelta, "series", true, dp, 2)
+	assert.False(t, ok, "the stale series should start over")
+}
+
+func TestCumulativeSumsConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.CumulativeSums.Rules = []CumulativeSumRule{{Metric: "system.*", Conversion: conversionRate}}
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.CumulativeSums.Rules[0].Metric = "system.["
+	assert.EqualError(t, component.ValidateConfig(cfg), `cumulative_sums: invalid metric pattern "system.["`)
+
+	cfg.CumulativeSums.Rules[0].Metric = "system.*"
+	cfg.CumulativeSums.Rules[0].Conversion = "increase"
+	assert.EqualError(t, component.ValidateConfig(cfg), `cumulative_sums: unsupported conversion "increase", must be none, delta or rate`)
+}
@@ -0,0 +1,213 @@
+package servicenowexporter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// redactedValue replaces the values of the redacted keys.
+const redactedValue = "<redacted>"
+
+// DebugConfig configures the troubleshooting output of the exporter. The payloads may hold
+// sensitive data, so none of them are written by default.
+type DebugConfig struct {
+	// PayloadSampling logs a sample of the payloads sent, at debug level
+	PayloadSampling PayloadSamplingConfig `mapstructure:"payload_sampling"`
+
+	// RedactedKeys are the attribute keys, and payload fields such as body or description, whose values
+	// are replaced before a payload is logged or written to the rejected payloads file
+	RedactedKeys []string `mapstructure:"redacted_keys"`
+
+	// RejectedPayloadsFile is a file the payloads rejected by ServiceNow with a 4xx status code are
+	// appended to, one JSON document per line. Disabled when empty.
+	RejectedPayloadsFile string `mapstructure:"rejected_payloads_file"`
+}
+
+// PayloadSamplingConfig limits the number of payloads logged.
+type PayloadSamplingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+
+	// MaxPayloads is the maximum number of payloads logged per interval
+	MaxPayloads int `mapstructure:"max_payloads"`
+
+	// Interval is the period MaxPayloads applies to
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+func (cfg *PayloadSamplingConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxPayloads <= 0 {
+		return errors.New("debug: payload_sampling max_payloads must be greater than 0")
+	}
+	if cfg.Interval <= 0 {
+		return errors.New("debug: payload_sampling interval must be greater than 0")
+	}
+	return nil
+}
+
+// payloadDebugger applies the DebugConfig to the payloads sent by the MID client.
+type payloadDebugger struct {
+	config   DebugConfig
+	logger   *zap.Logger
+	redacted map[string]bool
+
+	mu          sync.Mutex
+	windowStart time.Time
+	logged      int
+	rejected    *os.File
+	now         func() time.Time
+}
+
+// newPayloadDebugger opens the rejected payloads file when configured. The redacted keys also match
+// the additional_info keys, whose dots are replaced by additionalInfoKeySeparator.
+func newPayloadDebugger(cfg DebugConfig, additionalInfoKeySeparator string, logger *zap.Logger) (*payloadDebugger, error) {
+	d := &payloadDebugger{
+		config:   cfg,
+		logger:   logger,
+		redacted: make(map[string]bool),
+		now:      time.Now,
+	}
+	if additionalInfoKeySeparator == "" {
+		additionalInfoKeySeparator = "_"
+	}
+	for _, key := range cfg.RedactedKeys {
+		d.redacted[key] = true
+		d.redacted[strings.ReplaceAll(key, ".", additionalInfoKeySeparator)] = true
+	}
+
+	if cfg.RejectedPayloadsFile != "" {
+		file, err := os.OpenFile(cfg.RejectedPayloadsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		d.rejected = file
+	}
+	return d, nil
+}
+
+// sample logs the payload sent to an endpoint, unless the sampling limit was reached for the interval.
+func (d *payloadDebugger) sample(endpoint string, url string, body []byte) {
+	if !d.config.PayloadSampling.Enabled || !d.logger.Core().Enabled(zap.DebugLevel) || !d.allow() {
+		return
+	}
+	d.logger.Debug("Sampled payload sent to ServiceNow",
+		zap.String("endpoint", endpoint),
+		zap.String("url", url),
+		zap.ByteString("payload", d.redact(body)))
+}
+
+func (d *payloadDebugger) allow() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if now.Sub(d.windowStart) >= d.config.PayloadSampling.Interval {
+		d.windowStart = now
+		d.logged = 0
+	}
+	if d.logged >= d.config.PayloadSampling.MaxPayloads {
+		return false
+	}
+	d.logged++
+	return true
+}
+
+// rejectedPayload is a line of the rejected payloads file.
+type rejectedPayload struct {
+	Time       time.Time       `json:"time"`
+	Endpoint   string          `json:"endpoint"`
+	URL        string          `json:"url"`
+	StatusCode int             `json:"status_code"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// dumpRejected appends a payload rejected by an endpoint to the rejected payloads file.
+func (d *payloadDebugger) dumpRejected(endpoint string, url string, statusCode int, body []byte) {
+	if d.rejected == nil {
+		return
+	}
+	line, err := json.Marshal(rejectedPayload{
+		Time:       d.now(),
+		Endpoint:   endpoint,
+		URL:        url,
+		StatusCode: statusCode,
+		Payload:    d.redact(body),
+	})
+	if err != nil {
+		d.logger.Error("Failed to serialize rejected payload", zap.Error(err))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.rejected.Write(append(line, '\n')); err != nil {
+		d.logger.Error("Failed to write rejected payload", zap.String("file", d.config.RejectedPayloadsFile), zap.Error(err))
+	}
+}
+
+// redact returns the JSON payload with the values of the redacted keys replaced, including the
+// tags of the resource paths.
+func (d *payloadDebugger) redact(body []byte) []byte {
+	if len(d.redacted) == 0 {
+		return body
+	}
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return body
+	}
+	redacted, err := json.Marshal(d.redactValue("", payload))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func (d *payloadDebugger) redactValue(key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if d.redacted[k] {
+				v[k] = redactedValue
+				continue
+			}
+			v[k] = d.redactValue(k, item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = d.redactValue(key, item)
+		}
+	case string:
+		if key == "resource_path" {
+			return d.redactTags(v)
+		}
+	}
+	return value
+}
+
+// redactTags redacts the ;key=value tags of a resource path.
+func (d *payloadDebugger) redactTags(path string) string {
+	parts := strings.Split(path, tagPrefix)
+	for i := 1; i < len(parts); i++ {
+		key, _, found := strings.Cut(parts[i], tagKeyValueSeparator)
+		if found && d.redacted[key] {
+			parts[i] = key + tagKeyValueSeparator + redactedValue
+		}
+	}
+	return strings.Join(parts, tagPrefix)
+}
+
+func (d *payloadDebugger) Close() {
+	if d.rejected != nil {
+		if err := d.rejected.Close(); err != nil {
+			d.logger.Error("Failed to close rejected payloads file", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package servicenowexporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestPayloadDebuggerRedact(t *testing.T) {
+	d, err := newPayloadDebugger(DebugConfig{RedactedKeys: []string{"body", "user.email"}}, "_", zap.NewNop())
+	require.NoError(t, err)
+
+	logs, err := json.Marshal([]ServiceNowLog{{
+		Body:         "password=hunter2",
+		ResourcePath: "login;user.email=jane@example.com;status=ok",
+		Ci2LogID:     map[string]string{"user.email": "jane@example.com", "host.name": "host-1"},
+	}})
+	require.NoError(t, err)
+	var redacted []ServiceNowLog
+	require.NoError(t, json.Unmarshal(d.redact(logs), &redacted))
+	assert.Equal(t, redactedValue, redacted[0].Body)
+	assert.Equal(t, "login;user.email=<redacted>;status=ok", redacted[0].ResourcePath)
+	assert.Equal(t, map[string]string{"user.email": redactedValue, "host.name": "host-1"}, redacted[0].Ci2LogID)
+
+	events, err := json.Marshal([]ServiceNowEvent{{Description: "failed", AdditionalInfo: map[string]string{"user_email": "jane@example.com"}}})
+	require.NoError(t, err)
+	var redactedEvents []ServiceNowEvent
+	require.NoError(t, json.Unmarshal(d.redact(events), &redactedEvents))
+	assert.Equal(t, "failed", redactedEvents[0].Description)
+	assert.Equal(t, redactedValue, redactedEvents[0].AdditionalInfo["user_email"])
+}
+
+func TestPayloadDebuggerSampling(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	d, err := newPayloadDebugger(DebugConfig{
+		PayloadSampling: PayloadSamplingConfig{Enabled: true, MaxPayloads: 2, Interval: time.Minute},
+	}, "_", zap.New(core))
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	d.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		d.sample(endpointMetrics, "http://mid/metrics", []byte(`[]`))
+	}
+	assert.Equal(t, 2, logs.Len())
+
+	now = now.Add(time.Minute)
+	d.sample(endpointMetrics, "http://mid/metrics", []byte(`[]`))
+	assert.Equal(t, 3, logs.Len())
+	assert.Equal(t, "[]", logs.All()[2].ContextMap()["payload"])
+}
+
+func TestPayloadSamplingDisabledByDefault(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	d, err := newPayloadDebugger(createDefaultConfig().(*Config).Debug, "_", zap.New(core))
+	require.NoError(t, err)
+
+	d.sample(endpointMetrics, "http://mid/metrics", []byte(`[]`))
+	assert.Zero(t, logs.Len())
+}
+
+func TestRejectedPayloadsFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logs" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "rejected.jsonl")
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushLogsURL = server.URL + "/logs"
+	cfg.PushMetricsURL = server.URL + "/metrics"
+	cfg.Debug.RedactedKeys = []string{"body"}
+	cfg.Debug.RejectedPayloadsFile = file
+	client := newTestMidClient(t, cfg)
+
+	require.Error(t, client.sendLogs(context.Background(), []ServiceNowLog{{Body: "secret", Node: "host-1"}}))
+	// Only the payloads rejected with a 4xx status code are written, the others are retried.
+	require.Error(t, client.sendMetrics(context.Background(), []ServiceNowMetric{{MetricType: "cpu"}}))
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	var lines []rejectedPayload
+	for scanner.Scan() {
+		var line rejectedPayload
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 1)
+	assert.Equal(t, endpointLogs, lines[0].Endpoint)
+	assert.Equal(t, http.StatusBadRequest, lines[0].StatusCode)
+	var logs []ServiceNowLog
+	require.NoError(t, json.Unmarshal(lines[0].Payload, &logs))
+	assert.Equal(t, redactedValue, logs[0].Body)
+	assert.Equal(t, "host-1", logs[0].Node)
+}
+
+func TestDebugConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Debug.PayloadSampling.Enabled = true
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.Debug.PayloadSampling.MaxPayloads = 0
+	assert.EqualError(t, component.ValidateConfig(cfg), "debug: payload_sampling max_payloads must be greater than 0")
+}
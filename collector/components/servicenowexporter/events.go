@@ -0,0 +1,189 @@
+package servicenowexporter
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// EventsConfig defines the fields Event Management uses to deduplicate events and resolve alerts.
+//
+// The templates use the same ${key} placeholders as the mapping templates, looked up in the log record
+// or span attributes first and then in the resource attributes. The fields are not sent when empty.
+type EventsConfig struct {
+	// MessageKey is a template of the message_key field, events with the same message_key update the
+	// same alert. When empty, Event Management derives it from the source, node, type, resource and
+	// metric_name fields. Ex: "${k8s.namespace.name}/${k8s.pod.name}"
+	MessageKey string `mapstructure:"message_key"`
+
+	// EventClass is a template of the event_class field. Ex: "${service.name}"
+	EventClass string `mapstructure:"event_class"`
+
+	// MetricName is a template of the metric_name field. ${default} expands to the span name for span
+	// events, and to nothing for log events.
+	MetricName string `mapstructure:"metric_name"`
+
+	// SendClearEvents sends a severity 0 (Clear) event, resolving the alert, when a log record mapped
+	// to the Info severity or a span without an error status has the same message key as a previous
+	// event with a higher severity.
+	SendClearEvents bool `mapstructure:"send_clear_events"`
+
+	// OpenAlertsTTL is how long the message key of an alert is kept to send its Clear event when it is
+	// not raised again. Ex: 24h
+	OpenAlertsTTL time.Duration `mapstructure:"open_alerts_ttl"`
+}
+
+func (cfg *EventsConfig) Validate() error {
+	for _, t := range []string{cfg.MessageKey, cfg.EventClass, cfg.MetricName} {
+		if _, err := parseAttributeTemplate(t); err != nil {
+			return err
+		}
+	}
+	if cfg.SendClearEvents && cfg.OpenAlertsTTL <= 0 {
+		return errors.New("events: open_alerts_ttl must be greater than 0")
+	}
+	return nil
+}
+
+// eventFields sets the EventsConfig fields of events and keeps track of the open alerts.
+type eventFields struct {
+	messageKey *attributeTemplate
+	eventClass *attributeTemplate
+	metricName *attributeTemplate
+	mapper     *attributeMapper
+	ttl        time.Duration
+
+	mu sync.Mutex
+	// open holds the message keys of the alerts not resolved yet, with when they were last raised
+	open      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// alertChanges holds the alerts opened (true) or resolved (false) by the events of a request,
+// committed once the events are sent.
+type alertChanges map[string]bool
+
+// newEventFields returns an error for an invalid template.
+func newEventFields(cfg EventsConfig, mapper *attributeMapper) (*eventFields, error) {
+	f := &eventFields{
+		mapper: mapper,
+		ttl:    cfg.OpenAlertsTTL,
+		open:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+	var err error
+	if f.messageKey, err = parseAttributeTemplate(cfg.MessageKey); err != nil {
+		return nil, err
+	}
+	if f.eventClass, err = parseAttributeTemplate(cfg.EventClass); err != nil {
+		return nil, err
+	}
+	if f.metricName, err = parseAttributeTemplate(cfg.MetricName); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// set sets the message_key, event_class and metric_name of an event.
+func (f *eventFields) set(event *ServiceNowEvent, defaultMetricName string, resourceAttrs pcommon.Map, attrs pcommon.Map) {
+	if f.messageKey == nil && f.eventClass == nil && f.metricName == nil {
+		return
+	}
+	lookup := []map[string]string{f.mapper.ci2metricAttrs(attrs), f.mapper.ci2metricAttrs(resourceAttrs)}
+	if f.metricName != nil {
+		event.MetricName = f.metricName.render(defaultMetricName, lookup...)
+	}
+	if f.messageKey != nil {
+		event.MessageKey = f.messageKey.render("", lookup...)
+	}
+	if f.eventClass != nil {
+		event.EventClass = f.eventClass.render("", lookup...)
+	}
+}
+
+// alertKey returns the message key of an event, the one Event Management derives when not set.
+func alertKey(event *ServiceNowEvent) string {
+	if event.MessageKey != "" {
+		return event.MessageKey
+	}
+	return strings.Join([]string{event.Source, event.Node, event.Type, event.Resource, event.MetricName}, "__")
+}
+
+// hasOpenAlerts avoids building the events of healthy spans when there is nothing to resolve.
+func (f *eventFields) hasOpenAlerts(changes alertChanges) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.open) > 0 || len(changes) > 0
+}
+
+// isOpen returns whether the alert of a message key is open, once the changes are applied.
+func (f *eventFields) isOpen(key string, changes alertChanges) bool {
+	if open, ok := changes[key]; ok {
+		return open
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, open := f.open[key]
+	return open
+}
+
+// track records in changes the alert opened or resolved by an event, the open alerts being the
+// ones committed and the ones opened by the previous events of the request. An Info event resolving
+// an open alert is turned into a Clear event, it returns false for any other Info event that the
+// caller may skip.
+func (f *eventFields) track(event *ServiceNowEvent, changes alertChanges) bool {
+	key := alertKey(event)
+	open := f.isOpen(key, changes)
+	switch event.Severity {
+	case severityClear:
+		changes[key] = false
+		return true
+	case severityInfo:
+		if !open {
+			return false
+		}
+		changes[key] = false
+		event.Severity = severityClear
+		return true
+	default:
+		changes[key] = true
+		return true
+	}
+}
+
+// commit applies the changes of the events sent, so the alerts of events that were never sent
+// are not tracked.
+func (f *eventFields) commit(changes alertChanges) {
+	if len(changes) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	f.sweep(now)
+	for key, open := range changes {
+		if open {
+			f.open[key] = now
+		} else {
+			delete(f.open, key)
+		}
+	}
+}
+
+// sweep forgets the alerts not raised again for the ttl, at most once per ttl.
+func (f *eventFields) sweep(now time.Time) {
+	if now.Sub(f.lastSweep) < f.ttl {
+		return
+	}
+	f.lastSweep = now
+	for key, raised := range f.open {
+		if now.Sub(raised) >= f.ttl {
+			delete(f.open, key)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package servicenowexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+type podLog struct {
+	pod      string
+	severity plog.SeverityNumber
+}
+
+// createPodLogs returns a log record per podLog, all from the same resource.
+func createPodLogs(records ...podLog) plog.Logs {
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "cart")
+	logs := rl.ScopeLogs().AppendEmpty().LogRecords()
+	for _, r := range records {
+		log := logs.AppendEmpty()
+		log.SetSeverityNumber(r.severity)
+		log.Body().SetStr(r.severity.String() + " " + r.pod)
+		log.Attributes().PutStr("k8s.pod.name", r.pod)
+		log.Attributes().PutStr("reason", "OOMKilled")
+	}
+	return ld
+}
+
+func convertTestLogs(t *testing.T, producer *serviceNowProducer, ld plog.Logs) []ServiceNowEvent {
+	req, err := producer.convertLogs(context.Background(), ld)
+	require.NoError(t, err)
+	return req.(*serviceNowRequest).Events
+}
+
+// exportTestLogs converts and exports the logs, returning the events sent.
+func exportTestLogs(t *testing.T, producer *serviceNowProducer, ld plog.Logs) []ServiceNowEvent {
+	req, err := producer.convertLogs(context.Background(), ld)
+	require.NoError(t, err)
+	require.NoError(t, req.Export(context.Background()))
+	return req.(*serviceNowRequest).Events
+}
+
+func TestEventFields(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Events = EventsConfig{
+		MessageKey: "${k8s.pod.name}-${reason}",
+		EventClass: "otel-${service.name}",
+		MetricName: "${reason}",
+	}
+	producer := newTestProducer(t, cfg)
+
+	events := convertTestLogs(t, producer, createPodLogs(podLog{"cart-0", plog.SeverityNumberError}))
+	require.Len(t, events, 1)
+	assert.Equal(t, "cart-0-OOMKilled", events[0].MessageKey)
+	assert.Equal(t, "otel-cart", events[0].EventClass)
+	assert.Equal(t, "OOMKilled", events[0].MetricName)
+	assert.Equal(t, severityMajor, events[0].Severity)
+
+	// The fields are not set by default.
+	events = convertTestLogs(t, newTestProducer(t, createDefaultConfig().(*Config)), createPodLogs(podLog{"cart-0", plog.SeverityNumberError}))
+	assert.Empty(t, events[0].MessageKey)
+	assert.Empty(t, events[0].EventClass)
+	assert.Empty(t, events[0].MetricName)
+}
+
+func TestClearEventsFromLogs(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Events = EventsConfig{MessageKey: "${k8s.pod.name}", SendClearEvents: true, OpenAlertsTTL: time.Hour}
+	producer := newTestProducer(t, cfg)
+
+	events := exportTestLogs(t, producer, createPodLogs(
+		podLog{"cart-0", plog.SeverityNumberError},
+		podLog{"cart-1", plog.SeverityNumberInfo},
+	))
+	require.Len(t, events, 2)
+	assert.Equal(t, severityMajor, events[0].Severity)
+	assert.Equal(t, severityInfo, events[1].Severity)
+
+	// The alert of cart-0 is resolved once, by the next Info record.
+	events = exportTestLogs(t, producer, createPodLogs(
+		podLog{"cart-0", plog.SeverityNumberInfo},
+		podLog{"cart-0", plog.SeverityNumberInfo},
+	))
+	require.Len(t, events, 2)
+	assert.Equal(t, severityClear, events[0].Severity)
+	assert.Equal(t, "cart-0", events[0].MessageKey)
+	assert.Equal(t, "Info cart-0", events[0].Description)
+	assert.Equal(t, severityInfo, events[1].Severity)
+}
+
+func TestClearEventsAfterExport(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Events = EventsConfig{MessageKey: "${k8s.pod.name}", SendClearEvents: true, OpenAlertsTTL: time.Hour}
+	producer := newTestProducer(t, cfg)
+	now := time.Unix(1700000000, 0)
+	producer.events.now = func() time.Time { return now }
+
+	// The alert opened by an event that was not sent is not tracked.
+	req, err := producer.convertLogs(context.Background(), createPodLogs(podLog{"cart-0", plog.SeverityNumberError}))
+	require.NoError(t, err)
+	events := convertTestLogs(t, producer, createPodLogs(podLog{"cart-0", plog.SeverityNumberInfo}))
+	assert.Equal(t, severityInfo, events[0].Severity)
+
+	// It is once the event is sent.
+	require.NoError(t, req.Export(context.Background()))
+	events = convertTestLogs(t, producer, createPodLogs(podLog{"cart-0", plog.SeverityNumberInfo}))
+	assert.Equal(t, severityClear, events[0].Severity)
+
+	// The alerts not raised again are forgotten after the ttl.
+	now = now.Add(time.Hour)
+	exportTestLogs(t, producer, createPodLogs(podLog{"cart-1", plog.SeverityNumberError}))
+	assert.Len(t, producer.events.open, 1)
+	events = convertTestLogs(t, producer, createPodLogs(podLog{"cart-0", plog.SeverityNumberInfo}))
+	assert.Equal(t, severityInfo, events[0].Severity)
+}
+
+func TestClearEventsFromSpans(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Events.SendClearEvents = true
+	producer := newTestProducer(t, cfg)
+
+	exportSpans := func(codes ...ptrace.StatusCode) []ServiceNowEvent {
+		td := ptrace.NewTraces()
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", "checkout")
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+		for _, code := range codes {
+			span := spans.AppendEmpty()
+			span.SetName("GET /cart")
+			span.SetEndTimestamp(pcommon.NewTimestampFromTime(time.Unix(1700000000, 0)))
+			span.Status().SetCode(code)
+		}
+		req, err := producer.convertTraces(context.Background(), td)
+		require.NoError(t, err)
+		require.NoError(t, req.Export(context.Background()))
+		return req.(*serviceNowRequest).Events
+	}
+
+	// Nothing to resolve yet.
+	assert.Empty(t, exportSpans(ptrace.StatusCodeOk))
+
+	events := exportSpans(ptrace.StatusCodeError, ptrace.StatusCodeError)
+	require.Len(t, events, 2)
+	assert.Equal(t, spanErrorSeverity, events[0].Severity)
+
+	events = exportSpans(ptrace.StatusCodeUnset, ptrace.StatusCodeOk)
+	require.Len(t, events, 1)
+	assert.Equal(t, severityClear, events[0].Severity)
+	assert.Equal(t, "GET /cart", events[0].Type)
+	assert.Equal(t, "checkout", events[0].Resource)
+	assert.Equal(t, "Span GET /cart ended without an error", events[0].Description)
+
+	// An alert opened earlier in the same request is resolved as well.
+	events = exportSpans(ptrace.StatusCodeError, ptrace.StatusCodeOk)
+	require.Len(t, events, 2)
+	assert.Equal(t, severityClear, events[1].Severity)
+}
+
+func TestEventsConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Events.MessageKey = "${k8s.pod.name}"
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.Events.MessageKey = "${k8s.pod.name"
+	assert.Error(t, component.ValidateConfig(cfg))
+
+	cfg.Events = EventsConfig{SendClearEvents: true}
+	assert.EqualError(t, component.ValidateConfig(cfg), "events: open_alerts_ttl must be greater than 0")
+}
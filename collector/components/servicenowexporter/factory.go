@@ -23,17 +23,20 @@ func createMetricsExporter(
 		return nil, fmt.Errorf("cannot configure servicenow metrics exporter: %w", err)
 	}
 	oCfg := cfg.(*Config)
-	me := newServiceNowProducer(set.Logger, oCfg)
+	me, err := newServiceNowProducer(set.TelemetrySettings, oCfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure servicenow metrics exporter: %w", err)
+	}
 
-	return exporterhelper.NewMetricsExporter(
+	return exporterhelper.NewMetricsRequestExporter(
 		ctx,
 		set,
-		cfg,
-		me.metricsDataPusher,
+		me.convertMetrics,
 		// disable timeout
 		exporterhelper.WithTimeout(exporterhelper.TimeoutSettings{Timeout: 0}),
 		exporterhelper.WithRetry(oCfg.BackOffConfig),
-		exporterhelper.WithQueue(oCfg.QueueSettings),
+		exporterhelper.WithRequestQueue(me.queueConfig(), me.queueFactory()),
+		exporterhelper.WithStart(me.start),
 		exporterhelper.WithShutdown(me.Close),
 	)
 }
@@ -44,20 +47,50 @@ func createLogsExporter(
 	cfg component.Config,
 ) (exporter.Logs, error) {
 	if err := component.ValidateConfig(cfg); err != nil {
-		return nil, fmt.Errorf("cannot configure servicenow metrics exporter: %w", err)
+		return nil, fmt.Errorf("cannot configure servicenow logs exporter: %w", err)
 	}
 	oCfg := cfg.(*Config)
-	me := newServiceNowProducer(set.Logger, oCfg)
+	me, err := newServiceNowProducer(set.TelemetrySettings, oCfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure servicenow logs exporter: %w", err)
+	}
+
+	return exporterhelper.NewLogsRequestExporter(
+		ctx,
+		set,
+		me.convertLogs,
+		// disable timeout
+		exporterhelper.WithTimeout(exporterhelper.TimeoutSettings{Timeout: 0}),
+		exporterhelper.WithRetry(oCfg.BackOffConfig),
+		exporterhelper.WithRequestQueue(me.queueConfig(), me.queueFactory()),
+		exporterhelper.WithStart(me.start),
+		exporterhelper.WithShutdown(me.Close),
+	)
+}
+
+func createTracesExporter(
+	ctx context.Context,
+	set exporter.CreateSettings,
+	cfg component.Config,
+) (exporter.Traces, error) {
+	if err := component.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("cannot configure servicenow traces exporter: %w", err)
+	}
+	oCfg := cfg.(*Config)
+	me, err := newServiceNowProducer(set.TelemetrySettings, oCfg)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure servicenow traces exporter: %w", err)
+	}
 
-	return exporterhelper.NewLogsExporter(
+	return exporterhelper.NewTracesRequestExporter(
 		ctx,
 		set,
-		cfg,
-		me.logDataPusher,
+		me.convertTraces,
 		// disable timeout
 		exporterhelper.WithTimeout(exporterhelper.TimeoutSettings{Timeout: 0}),
 		exporterhelper.WithRetry(oCfg.BackOffConfig),
-		exporterhelper.WithQueue(oCfg.QueueSettings),
+		exporterhelper.WithRequestQueue(me.queueConfig(), me.queueFactory()),
+		exporterhelper.WithStart(me.start),
 		exporterhelper.WithShutdown(me.Close),
 	)
 }
@@ -68,5 +101,6 @@ func NewFactory() exporter.Factory {
 		createDefaultConfig,
 		exporter.WithMetrics(createMetricsExporter, metadata.MetricsStability),
 		exporter.WithLogs(createLogsExporter, metadata.LogsStability),
+		exporter.WithTraces(createTracesExporter, metadata.TracesStability),
 	)
 }
@@ -0,0 +1,295 @@
+package servicenowexporter
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	matchTypeStrict = "strict"
+	matchTypeRegexp = "regexp"
+	matchTypeGlob   = "glob"
+)
+
+// MetricsFilterConfig selects and renames the metrics sent to ServiceNow, before they are converted.
+// A data point is sent when it matches include, if set, and doesn't match exclude, if set.
+type MetricsFilterConfig struct {
+	// Include, when set, only sends the matching data points
+	Include *MetricMatchConfig `mapstructure:"include"`
+
+	// Exclude, when set, doesn't send the matching data points
+	Exclude *MetricMatchConfig `mapstructure:"exclude"`
+
+	// Rename maps metric names to ServiceNow metric types, the first matching rule is used
+	Rename []MetricRenameRule `mapstructure:"rename"`
+}
+
+// MetricMatchConfig matches data points by metric name, resource attributes and data point attributes.
+// All the specified conditions must match.
+type MetricMatchConfig struct {
+	// MatchType is how metric names and attribute values are matched: strict (default), regexp or glob
+	MatchType string `mapstructure:"match_type"`
+
+	// MetricNames match when any of them matches the metric name
+	MetricNames []string `mapstructure:"metric_names"`
+
+	// ResourceAttributes match when all of them match a resource attribute
+	ResourceAttributes []AttributeMatch `mapstructure:"resource_attributes"`
+
+	// Attributes match when all of them match a data point attribute
+	Attributes []AttributeMatch `mapstructure:"attributes"`
+}
+
+// AttributeMatch matches an attribute by key, and by value when set.
+type AttributeMatch struct {
+	Key   string `mapstructure:"key"`
+	Value string `mapstructure:"value"`
+}
+
+// MetricRenameRule renames the matching metrics. With the regexp match type, new_name
+// can reference the capture groups. Ex: metric: ^system\.(.*)$, new_name: host.$1
+type MetricRenameRule struct {
+	MatchType string `mapstructure:"match_type"`
+	Metric    string `mapstructure:"metric"`
+	NewName   string `mapstructure:"new_name"`
+}
+
+func (cfg *MetricsFilterConfig) Validate() error {
+	if _, err := newMetricFilter(*cfg); err != nil {
+		return err
+	}
+	return nil
+}
+
+// stringMatcher matches a string per the match type of its pattern.
+type stringMatcher struct {
+	matchType string
+	pattern   string
+	re        *regexp.Regexp
+}
+
+func newStringMatcher(matchType string, pattern string) (*stringMatcher, error) {
+	m := &stringMatcher{matchType: matchType, pattern: pattern}
+	switch matchType {
+	case "", matchTypeStrict:
+		m.matchType = matchTypeStrict
+	case matchTypeRegexp:
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("metrics_filter: invalid regexp %q: %w", pattern, err)
+		}
+		m.re = re
+	case matchTypeGlob:
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("metrics_filter: invalid glob %q: %w", pattern, err)
+		}
+	default:
+		return nil, fmt.Errorf("metrics_filter: unsupported match_type %q, must be %s, %s or %s", matchType, matchTypeStrict, matchTypeRegexp, matchTypeGlob)
+	}
+	return m, nil
+}
+
+func (m *stringMatcher) match(s string) bool {
+	switch m.matchType {
+	case matchTypeRegexp:
+		return m.re.MatchString(s)
+	case matchTypeGlob:
+		ok, _ := path.Match(m.pattern, s)
+		return ok
+	}
+	return s == m.pattern
+}
+
+type attributeMatcher struct {
+	key   string
+	value *stringMatcher
+}
+
+func (m attributeMatcher) match(attrs pcommon.Map) bool {
+	v, ok := attrs.Get(m.key)
+	if !ok {
+		return false
+	}
+	return m.value == nil || m.value.match(v.AsString())
+}
+
+type metricMatcher struct {
+	names              []*stringMatcher
+	resourceAttributes []attributeMatcher
+	attributes         []attributeMatcher
+}
+
+func newMetricMatcher(cfg *MetricMatchConfig) (*metricMatcher, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	m := &metricMatcher{}
+	for _, name := range cfg.MetricNames {
+		nm, err := newStringMatcher(cfg.MatchType, name)
+		if err != nil {
+			return nil, err
+		}
+		m.names = append(m.names, nm)
+	}
+	var err error
+	if m.resourceAttributes, err = newAttributeMatchers(cfg.MatchType, cfg.ResourceAttributes); err != nil {
+		return nil, err
+	}
+	if m.attributes, err = newAttributeMatchers(cfg.MatchType, cfg.Attributes); err != nil {
+		return nil, err
+	}
+	if len(m.names) == 0 && len(m.resourceAttributes) == 0 && len(m.attributes) == 0 {
+		return nil, errors.New("metrics_filter: at least one of metric_names, resource_attributes or attributes must be specified")
+	}
+	return m, nil
+}
+
+func newAttributeMatchers(matchType string, cfgs []AttributeMatch) ([]attributeMatcher, error) {
+	matchers := make([]attributeMatcher, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Key == "" {
+			return nil, errors.New("metrics_filter: attribute key must be specified")
+		}
+		am := attributeMatcher{key: cfg.Key}
+		if cfg.Value != "" {
+			value, err := newStringMatcher(matchType, cfg.Value)
+			if err != nil {
+				return nil, err
+			}
+			am.value = value
+		}
+		matchers = append(matchers, am)
+	}
+	return matchers, nil
+}
+
+// matchesMetric checks the metric name and resource attributes conditions.
+func (m *metricMatcher) matchesMetric(name string, rAttrs pcommon.Map) bool {
+	if len(m.names) > 0 {
+		matched := false
+		for _, nm := range m.names {
+			if nm.match(name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, am := range m.resourceAttributes {
+		if !am.match(rAttrs) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAttributes checks the data point attributes conditions.
+func (m *metricMatcher) matchesAttributes(attrs pcommon.Map) bool {
+	for _, am := range m.attributes {
+		if !am.match(attrs) {
+			return false
+		}
+	}
+	return true
+}
+
+type renameRule struct {
+	metric  *stringMatcher
+	newName string
+}
+
+// metricFilter applies the MetricsFilterConfig.
+type metricFilter struct {
+	include *metricMatcher
+	exclude *metricMatcher
+	renames []renameRule
+}
+
+// newMetricFilter returns an error when the config is invalid, the exporter expects a validated config.
+func newMetricFilter(cfg MetricsFilterConfig) (*metricFilter, error) {
+	f := &metricFilter{}
+	var err error
+	if f.include, err = newMetricMatcher(cfg.Include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = newMetricMatcher(cfg.Exclude); err != nil {
+		return nil, err
+	}
+	for _, rule := range cfg.Rename {
+		if rule.Metric == "" || rule.NewName == "" {
+			return nil, errors.New("metrics_filter: rename rules must specify metric and new_name")
+		}
+		m, err := newStringMatcher(rule.MatchType, rule.Metric)
+		if err != nil {
+			return nil, err
+		}
+		f.renames = append(f.renames, renameRule{metric: m, newName: rule.NewName})
+	}
+	return f, nil
+}
+
+// keepMetric returns false when none of the data points of the metric can be sent.
+func (f *metricFilter) keepMetric(name string, rAttrs pcommon.Map) bool {
+	if f.include != nil && !f.include.matchesMetric(name, rAttrs) {
+		return false
+	}
+	if f.exclude != nil && len(f.exclude.attributes) == 0 && f.exclude.matchesMetric(name, rAttrs) {
+		return false
+	}
+	return true
+}
+
+// filterDataPoints returns the metric with only the data points to send. The metric is copied
+// when there are data point attributes conditions, to leave the original data unchanged.
+func (f *metricFilter) filterDataPoints(metric pmetric.Metric, rAttrs pcommon.Map) pmetric.Metric {
+	includeAttrs := f.include != nil && len(f.include.attributes) > 0
+	excludeAttrs := f.exclude != nil && len(f.exclude.attributes) > 0 && f.exclude.matchesMetric(metric.Name(), rAttrs)
+	if !includeAttrs && !excludeAttrs {
+		return metric
+	}
+
+	remove := func(attrs pcommon.Map) bool {
+		if includeAttrs && !f.include.matchesAttributes(attrs) {
+			return true
+		}
+		return excludeAttrs && f.exclude.matchesAttributes(attrs)
+	}
+
+	filtered := pmetric.NewMetric()
+	metric.CopyTo(filtered)
+	switch filtered.Type() {
+	case pmetric.MetricTypeGauge:
+		filtered.Gauge().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool { return remove(dp.Attributes()) })
+	case pmetric.MetricTypeSum:
+		filtered.Sum().DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool { return remove(dp.Attributes()) })
+	case pmetric.MetricTypeHistogram:
+		filtered.Histogram().DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool { return remove(dp.Attributes()) })
+	case pmetric.MetricTypeExponentialHistogram:
+		filtered.ExponentialHistogram().DataPoints().RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool { return remove(dp.Attributes()) })
+	case pmetric.MetricTypeSummary:
+		filtered.Summary().DataPoints().RemoveIf(func(dp pmetric.SummaryDataPoint) bool { return remove(dp.Attributes()) })
+	}
+	return filtered
+}
+
+// rename returns the ServiceNow metric type of a metric.
+func (f *metricFilter) rename(name string) string {
+	for _, rule := range f.renames {
+		if !rule.metric.match(name) {
+			continue
+		}
+		if rule.metric.re != nil {
+			return rule.metric.re.ReplaceAllString(name, rule.newName)
+		}
+		return rule.newName
+	}
+	return name
+}
@@ -0,0 +1,139 @@
+package servicenowexporter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+func createTestHostMetrics() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("host.name", "host-1")
+	metrics := rm.ScopeMetrics().AppendEmpty().Metrics()
+
+	cpu := metrics.AppendEmpty()
+	cpu.SetName("system.cpu.utilization")
+	gauge := cpu.SetEmptyGauge()
+	for _, state := range []string{"user", "system", "idle"} {
+		dp := gauge.DataPoints().AppendEmpty()
+		dp.Attributes().PutStr("state", state)
+		dp.SetDoubleValue(0.1)
+	}
+
+	memory := metrics.AppendEmpty()
+	memory.SetName("system.memory.usage")
+	memory.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1024)
+
+	disk := metrics.AppendEmpty()
+	disk.SetName("system.disk.io")
+	disk.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(10)
+	return md
+}
+
+func TestMetricsFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter MetricsFilterConfig
+		want   []string
+	}{
+		{
+			name: "none",
+			want: []string{
+				"system.cpu.utilization;state=user", "system.cpu.utilization;state=system", "system.cpu.utilization;state=idle",
+				"system.memory.usage", "system.disk.io",
+			},
+		},
+		{
+			name: "include glob",
+			filter: MetricsFilterConfig{
+				Include: &MetricMatchConfig{MatchType: matchTypeGlob, MetricNames: []string{"system.cpu.*", "system.memory.*"}},
+			},
+			want: []string{
+				"system.cpu.utilization;state=user", "system.cpu.utilization;state=system", "system.cpu.utilization;state=idle",
+				"system.memory.usage",
+			},
+		},
+		{
+			name: "exclude regexp",
+			filter: MetricsFilterConfig{
+				Exclude: &MetricMatchConfig{MatchType: matchTypeRegexp, MetricNames: []string{`^system\.(disk|memory)\.`}},
+			},
+			want: []string{"system.cpu.utilization;state=user", "system.cpu.utilization;state=system", "system.cpu.utilization;state=idle"},
+		},
+		{
+			name: "exclude data point attributes",
+			filter: MetricsFilterConfig{
+				Exclude: &MetricMatchConfig{
+					MetricNames: []string{"system.cpu.utilization"},
+					Attributes:  []AttributeMatch{{Key: "state", Value: "idle"}},
+				},
+			},
+			want: []string{
+				"system.cpu.utilization;state=user", "system.cpu.utilization;state=system",
+				"system.memory.usage", "system.disk.io",
+			},
+		},
+		{
+			name: "include resource attributes",
+			filter: MetricsFilterConfig{
+				Include: &MetricMatchConfig{ResourceAttributes: []AttributeMatch{{Key: "host.name", Value: "host-2"}}},
+			},
+		},
+		{
+			name: "rename",
+			filter: MetricsFilterConfig{
+				Include: &MetricMatchConfig{MetricNames: []string{"system.memory.usage", "system.disk.io"}},
+				Rename: []MetricRenameRule{
+					{Metric: "system.memory.usage", NewName: "Memory Usage"},
+					{MatchType: matchTypeRegexp, Metric: `^system\.(.*)$`, NewName: "host.$1"},
+				},
+			},
+			want: []string{"Memory Usage", "host.disk.io"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := createTestHostMetrics()
+			if tt.want == nil {
+				mid := newMidServerMock(t)
+				cfg := mid.config()
+				cfg.MetricsFilter = tt.filter
+				producer := newTestProducer(t, cfg)
+				assert.NoError(t, exportRequest(producer.convertMetrics(context.Background(), md)))
+				// Nothing is sent when every metric is filtered out.
+				assert.Empty(t, mid.received("/metrics"))
+				return
+			}
+
+			metrics := sendTestMetrics(t, func(cfg *Config) { cfg.MetricsFilter = tt.filter }, md)
+			paths := make([]string, 0, len(metrics))
+			for path, m := range metrics {
+				paths = append(paths, path)
+				assert.Equal(t, "host-1", m.Node)
+			}
+			assert.ElementsMatch(t, tt.want, paths)
+			// The data points are filtered on a copy.
+			assert.Equal(t, 3, md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Gauge().DataPoints().Len())
+		})
+	}
+}
+
+func TestMetricsFilterConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.MetricsFilter.Include = &MetricMatchConfig{MatchType: matchTypeRegexp, MetricNames: []string{"system.("}}
+	assert.ErrorContains(t, component.ValidateConfig(cfg), `metrics_filter: invalid regexp "system.("`)
+
+	cfg.MetricsFilter.Include = &MetricMatchConfig{MatchType: "exact", MetricNames: []string{"system.cpu"}}
+	assert.EqualError(t, component.ValidateConfig(cfg), `metrics_filter: unsupported match_type "exact", must be strict, regexp or glob`)
+
+	cfg.MetricsFilter.Include = &MetricMatchConfig{}
+	assert.EqualError(t, component.ValidateConfig(cfg), "metrics_filter: at least one of metric_names, resource_attributes or attributes must be specified")
+
+	cfg.MetricsFilter.Include = nil
+	cfg.MetricsFilter.Rename = []MetricRenameRule{{Metric: "system.cpu.utilization"}}
+	assert.EqualError(t, component.ValidateConfig(cfg), "metrics_filter: rename rules must specify metric and new_name")
+}
@@ -0,0 +1,247 @@
+package servicenowexporter
+
+import (
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+const (
+	// histogramStrategyBuckets sends the count, sum and a metric per bucket.
+	histogramStrategyBuckets = "buckets"
+	// histogramStrategyPercentiles sends the count, sum and percentiles computed from the buckets.
+	histogramStrategyPercentiles = "percentiles"
+	// histogramStrategyMinMaxAvg sends only the min, max and average values.
+	histogramStrategyMinMaxAvg = "min_max_avg"
+
+	// Suffixes added to the original metric name by the min_max_avg strategy.
+	minSuffix = ".min"
+	maxSuffix = ".max"
+	avgSuffix = ".avg"
+)
+
+// HistogramsConfig defines how histogram and exponential histogram metrics are sent.
+type HistogramsConfig struct {
+	// Strategy is one of buckets (default), percentiles or min_max_avg
+	Strategy string `mapstructure:"strategy"`
+
+	// Percentiles computed by the percentiles strategy, between 0 and 100
+	Percentiles []float64 `mapstructure:"percentiles"`
+}
+
+func (cfg *HistogramsConfig) Validate() error {
+	switch cfg.Strategy {
+	case histogramStrategyBuckets, histogramStrategyMinMaxAvg:
+	case histogramStrategyPercentiles:
+		if len(cfg.Percentiles) == 0 {
+			return fmt.Errorf("histograms: percentiles must be specified for the %s strategy", histogramStrategyPercentiles)
+		}
+		for _, p := range cfg.Percentiles {
+			if p <= 0 || p > 100 {
+				return fmt.Errorf("histograms: invalid percentile %v, must be greater than 0 and at most 100", p)
+			}
+		}
+	default:
+		return fmt.Errorf("histograms: unsupported strategy %q, must be %s, %s or %s",
+			cfg.Strategy, histogramStrategyBuckets, histogramStrategyPercentiles, histogramStrategyMinMaxAvg)
+	}
+	return nil
+}
+
+// histogramBucket counts the values in (lower, upper].
+type histogramBucket struct {
+	lower float64
+	upper float64
+	count uint64
+}
+
+// histogramPoint is the common representation of explicit and exponential histogram data points.
+type histogramPoint struct {
+	attributes pcommon.Map
+	timestamp  pcommon.Timestamp
+	count      uint64
+	sum        float64
+	hasMin     bool
+	min        float64
+	hasMax     bool
+	max        float64
+	// buckets are sorted by bound, the first lower and last upper bounds may be infinite.
+	buckets []histogramBucket
+}
+
+func newExplicitHistogramPoint(dp pmetric.HistogramDataPoint) histogramPoint {
+	p := histogramPoint{
+		attributes: dp.Attributes(),
+		timestamp:  dp.Timestamp(),
+		count:      dp.Count(),
+		sum:        dp.Sum(),
+		hasMin:     dp.HasMin(),
+		min:        dp.Min(),
+		hasMax:     dp.HasMax(),
+		max:        dp.Max(),
+	}
+	if dp.ExplicitBounds().Len() == 0 {
+		return p
+	}
+
+	lower := math.Inf(-1)
+	for i := 0; i < dp.BucketCounts().Len(); i++ {
+		upper := math.Inf(1)
+		if i < dp.ExplicitBounds().Len() {
+			upper = dp.ExplicitBounds().At(i)
+		}
+		p.buckets = append(p.buckets, histogramBucket{lower: lower, upper: upper, count: dp.BucketCounts().At(i)})
+		lower = upper
+	}
+	return p
+}
+
+// newExponentialHistogramPoint converts the exponential buckets to explicit bounds, see
+// https://opentelemetry.io/docs/specs/otel/metrics/data-model/#exponentialhistogram
+func newExponentialHistogramPoint(dp pmetric.ExponentialHistogramDataPoint) histogramPoint {
+	p := histogramPoint{
+		attributes: dp.Attributes(),
+		timestamp:  dp.Timestamp(),
+		count:      dp.Count(),
+		sum:        dp.Sum(),
+		hasMin:     dp.HasMin(),
+		min:        dp.Min(),
+		hasMax:     dp.HasMax(),
+		max:        dp.Max(),
+	}
+
+	// The bucket at index i holds the values in (base^i, base^(i+1)], with base = 2^(2^-scale).
+	factor := math.Exp2(-float64(dp.Scale()))
+	bound := func(index int) float64 {
+		return math.Exp2(float64(index) * factor)
+	}
+
+	negative := dp.Negative()
+	for i := negative.BucketCounts().Len() - 1; i >= 0; i-- {
+		index := int(negative.Offset()) + i
+		p.buckets = append(p.buckets, histogramBucket{lower: -bound(index + 1), upper: -bound(index), count: negative.BucketCounts().At(i)})
+	}
+	if dp.ZeroCount() > 0 {
+		p.buckets = append(p.buckets, histogramBucket{lower: -dp.ZeroThreshold(), upper: dp.ZeroThreshold(), count: dp.ZeroCount()})
+	}
+	positive := dp.Positive()
+	for i := 0; i < positive.BucketCounts().Len(); i++ {
+		index := int(positive.Offset()) + i
+		p.buckets = append(p.buckets, histogramBucket{lower: bound(index), upper: bound(index + 1), count: positive.BucketCounts().At(i)})
+	}
+	return p
+}
+
+// percentile estimates the value at percentile q (0-100) by linear interpolation within
+// the bucket holding it. Returns false when there are no values.
+func (p histogramPoint) percentile(q float64) (float64, bool) {
+	var total uint64
+	for _, b := range p.buckets {
+		total += b.count
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	rank := q / 100 * float64(total)
+	var cumulative uint64
+	for _, b := range p.buckets {
+		if b.count == 0 {
+			continue
+		}
+		if float64(cumulative+b.count) < rank {
+			cumulative += b.count
+			continue
+		}
+
+		lower, upper := b.lower, b.upper
+		if p.hasMin && lower < p.min {
+			lower = p.min
+		}
+		if p.hasMax && upper > p.max {
+			upper = p.max
+		}
+		if math.IsInf(lower, -1) {
+			if upper <= 0 {
+				return upper, true
+			}
+			lower = 0
+		}
+		if math.IsInf(upper, 1) {
+			return lower, true
+		}
+		return lower + (upper-lower)*(rank-float64(cumulative))/float64(b.count), true
+	}
+	return p.buckets[len(p.buckets)-1].upper, true
+}
+
+// formatHistogramPoint transforms a histogram data point into a series of metrics per the configured strategy:
+//
+// 1. buckets: the count, the sum and a metric named "<metricName>.bucket" per bucket, with an "upper_bound" tag.
+//
+// 2. percentiles: the count, the sum and a metric named "<metricName>.quantile" per configured percentile,
+// with a "quantile" tag, like summary metrics.
+//
+// 3. min_max_avg: metrics named "<metricName>.min", "<metricName>.max" and "<metricName>.avg". Min and max are
+// only sent when set on the data point.
+func (e *serviceNowProducer) formatHistogramPoint(metricName string, scope string, rAttrs pcommon.Map, p histogramPoint) []ServiceNowMetric {
+	timestamp := formatTimestamp(p.timestamp)
+
+	if e.config.Histograms.Strategy == histogramStrategyMinMaxAvg {
+		snm := make([]ServiceNowMetric, 0, 3)
+		if p.hasMin {
+			snm = append(snm, e.createMetric(metricName+minSuffix, scope, e.mapper.ci2metricAttrs(rAttrs),
+				e.mapper.formatResourcePath(e.mapper.buildPath(metricName+minSuffix, p.attributes), rAttrs, p.attributes), p.min, timestamp))
+		}
+		if p.hasMax {
+			snm = append(snm, e.createMetric(metricName+maxSuffix, scope, e.mapper.ci2metricAttrs(rAttrs),
+				e.mapper.formatResourcePath(e.mapper.buildPath(metricName+maxSuffix, p.attributes), rAttrs, p.attributes), p.max, timestamp))
+		}
+		if p.count > 0 {
+			snm = append(snm, e.createMetric(metricName+avgSuffix, scope, e.mapper.ci2metricAttrs(rAttrs),
+				e.mapper.formatResourcePath(e.mapper.buildPath(metricName+avgSuffix, p.attributes), rAttrs, p.attributes), p.sum/float64(p.count), timestamp))
+		}
+		return snm
+	}
+
+	snm := e.formatCountAndSum(metricName, scope, rAttrs, p.attributes, p.count, p.sum, p.timestamp)
+	if len(p.buckets) == 0 {
+		return snm
+	}
+
+	if e.config.Histograms.Strategy == histogramStrategyPercentiles {
+		quantilePath := e.mapper.formatResourcePath(e.mapper.buildPath(metricName+summaryQuantileSuffix, p.attributes), rAttrs, p.attributes)
+		for _, q := range e.config.Histograms.Percentiles {
+			value, ok := p.percentile(q)
+			if !ok {
+				break
+			}
+			snm = append(snm, e.createMetric(
+				metricName+summaryQuantileSuffix,
+				scope,
+				e.mapper.ci2metricAttrs(rAttrs),
+				quantilePath+summaryQuantileTagBeforeValue+formatFloatForLabel(q),
+				value,
+				timestamp))
+		}
+		return snm
+	}
+
+	bucketPath := e.mapper.formatResourcePath(e.mapper.buildPath(metricName+distributionBucketSuffix, p.attributes), rAttrs, p.attributes)
+	for _, b := range p.buckets {
+		upperBound := infinityCarbonValue
+		if !math.IsInf(b.upper, 1) {
+			upperBound = formatFloatForLabel(b.upper)
+		}
+		snm = append(snm, e.createMetric(
+			metricName+distributionBucketSuffix,
+			scope,
+			e.mapper.ci2metricAttrs(rAttrs),
+			bucketPath+distributionUpperBoundTagBeforeValue+upperBound,
+			float64(b.count),
+			timestamp))
+	}
+	return snm
+}
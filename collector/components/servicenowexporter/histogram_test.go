@@ -0,0 +1,156 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// sendTestMetrics pushes md and returns the metrics received, by resource path.
+func sendTestMetrics(t *testing.T, cfg func(*Config), md pmetric.Metrics) map[string]ServiceNowMetric {
+	mid := newMidServerMock(t)
+	config := mid.config()
+	if cfg != nil {
+		cfg(config)
+	}
+	producer := newTestProducer(t, config)
+	require.NoError(t, exportRequest(producer.convertMetrics(context.Background(), md)))
+
+	payloads := mid.received("/metrics")
+	require.Len(t, payloads, 1)
+	var metrics []ServiceNowMetric
+	require.NoError(t, json.Unmarshal(payloads[0], &metrics))
+
+	byPath := make(map[string]ServiceNowMetric, len(metrics))
+	for _, m := range metrics {
+		byPath[m.ResourcePath] = m
+	}
+	require.Len(t, byPath, len(metrics))
+	return byPath
+}
+
+func createTestHistogram() pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("latency")
+	dp := metric.SetEmptyHistogram().DataPoints().AppendEmpty()
+	dp.SetCount(10)
+	dp.SetSum(250)
+	dp.SetMin(2)
+	dp.SetMax(80)
+	dp.ExplicitBounds().FromRaw([]float64{10, 50, 100})
+	dp.BucketCounts().FromRaw([]uint64{4, 4, 2, 0})
+	return md
+}
+
+func TestHistogramBucketsStrategy(t *testing.T) {
+	metrics := sendTestMetrics(t, nil, createTestHistogram())
+
+	assert.Len(t, metrics, 6)
+	assert.Equal(t, "latency.count", metrics["latency.count"].MetricType)
+	assert.Equal(t, 10.0, metrics["latency.count"].Value)
+	assert.Equal(t, "latency", metrics["latency"].MetricType)
+	assert.Equal(t, 250.0, metrics["latency"].Value)
+	assert.Equal(t, 4.0, metrics["latency.bucket;upper_bound=10"].Value)
+	assert.Equal(t, 2.0, metrics["latency.bucket;upper_bound=100"].Value)
+	assert.Equal(t, 0.0, metrics["latency.bucket;upper_bound=inf"].Value)
+}
+
+func TestHistogramPercentilesStrategy(t *testing.T) {
+	metrics := sendTestMetrics(t, func(cfg *Config) {
+		cfg.Histograms.Strategy = histogramStrategyPercentiles
+	}, createTestHistogram())
+
+	assert.Len(t, metrics, 5)
+	assert.Equal(t, 10.0, metrics["latency.count"].Value)
+	assert.Equal(t, "latency.quantile", metrics["latency.quantile;quantile=50"].MetricType)
+	// The 5th value is the first of the (10, 50] bucket.
+	assert.InDelta(t, 20, metrics["latency.quantile;quantile=50"].Value, 1e-9)
+	// The 9th and 10th values are in the (50, 100] bucket, clamped by the max.
+	assert.InDelta(t, 65, metrics["latency.quantile;quantile=90"].Value, 1e-9)
+	assert.InDelta(t, 78.5, metrics["latency.quantile;quantile=99"].Value, 1e-9)
+}
+
+func TestHistogramMinMaxAvgStrategy(t *testing.T) {
+	metrics := sendTestMetrics(t, func(cfg *Config) {
+		cfg.Histograms.Strategy = histogramStrategyMinMaxAvg
+	}, createTestHistogram())
+
+	assert.Len(t, metrics, 3)
+	assert.Equal(t, 2.0, metrics["latency.min"].Value)
+	assert.Equal(t, 80.0, metrics["latency.max"].Value)
+	assert.Equal(t, 25.0, metrics["latency.avg"].Value)
+	assert.Equal(t, "latency.avg", metrics["latency.avg"].MetricType)
+}
+
+func TestExponentialHistogram(t *testing.T) {
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("size")
+	dp := metric.SetEmptyExponentialHistogram().DataPoints().AppendEmpty()
+	dp.SetCount(7)
+	dp.SetSum(20)
+	// base 2: buckets (1, 2], (2, 4], (4, 8]
+	dp.SetScale(0)
+	dp.SetZeroCount(1)
+	dp.Positive().SetOffset(0)
+	dp.Positive().BucketCounts().FromRaw([]uint64{2, 3, 0})
+	// (-2, -1]
+	dp.Negative().SetOffset(0)
+	dp.Negative().BucketCounts().FromRaw([]uint64{1})
+
+	metrics := sendTestMetrics(t, nil, md)
+	assert.Len(t, metrics, 7)
+	assert.Equal(t, 7.0, metrics["size.count"].Value)
+	assert.Equal(t, 20.0, metrics["size"].Value)
+	assert.Equal(t, 1.0, metrics["size.bucket;upper_bound=-1"].Value)
+	assert.Equal(t, 1.0, metrics["size.bucket;upper_bound=0"].Value)
+	assert.Equal(t, 2.0, metrics["size.bucket;upper_bound=2"].Value)
+	assert.Equal(t, 3.0, metrics["size.bucket;upper_bound=4"].Value)
+	assert.Equal(t, 0.0, metrics["size.bucket;upper_bound=8"].Value)
+
+	metrics = sendTestMetrics(t, func(cfg *Config) {
+		cfg.Histograms.Strategy = histogramStrategyPercentiles
+		cfg.Histograms.Percentiles = []float64{50}
+	}, md)
+	// The 3.5th value is in the (1, 2] bucket, after the negative and zero values.
+	assert.InDelta(t, 1.75, metrics["size.quantile;quantile=50"].Value, 1e-9)
+}
+
+func TestSummaryCountAndSum(t *testing.T) {
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("rpc")
+	dp := metric.SetEmptySummary().DataPoints().AppendEmpty()
+	dp.SetCount(3)
+	dp.SetSum(12)
+	q := dp.QuantileValues().AppendEmpty()
+	q.SetQuantile(0.5)
+	q.SetValue(4)
+
+	metrics := sendTestMetrics(t, nil, md)
+	assert.Len(t, metrics, 3)
+	assert.Equal(t, 3.0, metrics["rpc.count"].Value)
+	assert.Equal(t, 12.0, metrics["rpc"].Value)
+	assert.Equal(t, 4.0, metrics["rpc.quantile;quantile=50"].Value)
+}
+
+func TestHistogramsConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Histograms.Strategy = histogramStrategyPercentiles
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.Histograms.Percentiles = []float64{0}
+	assert.EqualError(t, component.ValidateConfig(cfg), "histograms: invalid percentile 0, must be greater than 0 and at most 100")
+
+	cfg.Histograms.Percentiles = nil
+	assert.EqualError(t, component.ValidateConfig(cfg), "histograms: percentiles must be specified for the percentiles strategy")
+
+	cfg.Histograms.Strategy = "average"
+	assert.EqualError(t, component.ValidateConfig(cfg), `histograms: unsupported strategy "average", must be buckets, percentiles or min_max_avg`)
+}
@@ -0,0 +1,210 @@
+package servicenowexporter
+
+import (
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// Kubernetes event types.
+const (
+	k8sEventTypeNormal  = "Normal"
+	k8sEventTypeWarning = "Warning"
+)
+
+// defaultK8sReasonSeverities are the severities of the Warning events with well known reasons.
+var defaultK8sReasonSeverities = map[string]string{
+	"BackOff":                severityMajor,
+	"Failed":                 severityMajor,
+	"FailedCreatePodSandBox": severityMajor,
+	"Evicted":                severityMajor,
+	"OOMKilling":             severityCritical,
+	"NodeNotReady":           severityCritical,
+	"FailedScheduling":       severityMinor,
+	"FailedMount":            severityMinor,
+	"FailedAttachVolume":     severityMinor,
+	"Unhealthy":              severityMinor,
+}
+
+// KubernetesEventsConfig defines how the Kubernetes events collected by the k8s_events receiver, or
+// by the k8sobjects receiver watching or pulling the events resource, are mapped to ServiceNow events
+// once enabled:
+//
+//   - type is the event reason and description the event message
+//   - resource is the kind and name of the involved object. Ex: Pod/api-7c9f
+//   - node is the k8s.cluster.name resource attribute, or the mapped node when not set
+//   - additional_info holds the namespace, cluster and involved object details
+//   - message_key is the involved object UID and the reason, unless events.message_key is set, so
+//     repeated events such as a pod crash loop update the same alert
+//
+// The notifications of the k8sobjects receiver about deleted events are not sent.
+type KubernetesEventsConfig struct {
+	// Enabled turns on the mapping, the log records are sent as any other log otherwise. Disabled by default.
+	Enabled bool `mapstructure:"enabled"`
+
+	// ReasonSeverities maps the reason of Warning events to a ServiceNow severity, checked before the
+	// default reasons. Ex: {"BackOff": "2"}
+	ReasonSeverities map[string]string `mapstructure:"reason_severities"`
+
+	// WarningSeverity is the severity of the Warning events with a reason not mapped, Warning (4) by default.
+	// Normal events are always sent with the Info (5) severity.
+	WarningSeverity string `mapstructure:"warning_severity"`
+}
+
+func (cfg *KubernetesEventsConfig) Validate() error {
+	for _, sev := range cfg.ReasonSeverities {
+		if err := validateSeverity(sev); err != nil {
+			return err
+		}
+	}
+	if cfg.WarningSeverity != "" {
+		return validateSeverity(cfg.WarningSeverity)
+	}
+	return nil
+}
+
+// k8sEvent holds the fields of a Kubernetes event used by the mapping.
+type k8sEvent struct {
+	eventType string
+	reason    string
+	message   string
+	kind      string
+	name      string
+	namespace string
+	uid       string
+	host      string
+	// deleted is set for the notifications of the k8sobjects receiver about deleted events
+	deleted bool
+}
+
+// parseK8sEvent returns the Kubernetes event held by a log record, if any.
+func parseK8sEvent(log plog.LogRecord, resourceAttrs pcommon.Map) (k8sEvent, bool) {
+	// k8s_events receiver
+	if reason, ok := log.Attributes().Get("k8s.event.reason"); ok {
+		if _, ok := resourceAttrs.Get("k8s.object.uid"); ok {
+			return k8sEvent{
+				eventType: log.SeverityText(),
+				reason:    reason.AsString(),
+				message:   log.Body().AsString(),
+				kind:      mapString(resourceAttrs, "k8s.object.kind"),
+				name:      mapString(resourceAttrs, "k8s.object.name"),
+				namespace: mapString(log.Attributes(), "k8s.namespace.name"),
+				uid:       mapString(resourceAttrs, "k8s.object.uid"),
+				host:      mapString(resourceAttrs, "k8s.node.name"),
+			}, true
+		}
+	}
+
+	// k8sobjects receiver, the body is the event object in pull mode and a watch notification in watch mode
+	if mapString(log.Attributes(), "k8s.resource.name") != "events" || log.Body().Type() != pcommon.ValueTypeMap {
+		return k8sEvent{}, false
+	}
+	object := log.Body().Map()
+	var deleted bool
+	if v, ok := object.Get("object"); ok && v.Type() == pcommon.ValueTypeMap {
+		deleted = mapString(object, "type") == "DELETED"
+		object = v.Map()
+	}
+
+	ev := k8sEvent{
+		eventType: mapString(object, "type"),
+		reason:    mapString(object, "reason"),
+		message:   mapString(object, "message"),
+		deleted:   deleted,
+	}
+	// core/v1 events use involvedObject, events.k8s.io/v1 events use regarding and note
+	involved, ok := object.Get("involvedObject")
+	if !ok {
+		involved, ok = object.Get("regarding")
+	}
+	if ok && involved.Type() == pcommon.ValueTypeMap {
+		ev.kind = mapString(involved.Map(), "kind")
+		ev.name = mapString(involved.Map(), "name")
+		ev.namespace = mapString(involved.Map(), "namespace")
+		ev.uid = mapString(involved.Map(), "uid")
+	}
+	if ev.message == "" {
+		ev.message = mapString(object, "note")
+	}
+	if source, ok := object.Get("source"); ok && source.Type() == pcommon.ValueTypeMap {
+		ev.host = mapString(source.Map(), "host")
+	}
+	if ev.host == "" {
+		ev.host = mapString(object, "reportingInstance")
+	}
+	return ev, ev.reason != ""
+}
+
+func mapString(m pcommon.Map, key string) string {
+	if v, ok := m.Get(key); ok {
+		return v.AsString()
+	}
+	return ""
+}
+
+// k8sEventMapper applies the KubernetesEventsConfig.
+type k8sEventMapper struct {
+	config KubernetesEventsConfig
+	mapper *attributeMapper
+}
+
+func newK8sEventMapper(cfg KubernetesEventsConfig, mapper *attributeMapper) *k8sEventMapper {
+	if cfg.WarningSeverity == "" {
+		cfg.WarningSeverity = severityWarning
+	}
+	return &k8sEventMapper{config: cfg, mapper: mapper}
+}
+
+// severity returns the severity of a Kubernetes event, defaulting to the log record severity
+// for unknown event types.
+func (m *k8sEventMapper) severity(ev k8sEvent, defaultSeverity string) string {
+	switch ev.eventType {
+	case k8sEventTypeNormal:
+		return severityInfo
+	case k8sEventTypeWarning:
+		if sev, ok := m.config.ReasonSeverities[ev.reason]; ok {
+			return sev
+		}
+		if sev, ok := defaultK8sReasonSeverities[ev.reason]; ok {
+			return sev
+		}
+		return m.config.WarningSeverity
+	default:
+		return defaultSeverity
+	}
+}
+
+// apply overrides the fields of an event created from a log record holding a Kubernetes event.
+func (m *k8sEventMapper) apply(event *ServiceNowEvent, ev k8sEvent, resourceAttrs pcommon.Map, attrs pcommon.Map) {
+	event.Type = ev.reason
+	event.Description = ev.message
+	event.Severity = m.severity(ev, event.Severity)
+	event.Resource = m.mapper.formatResource(ev.kind+"/"+ev.name, resourceAttrs, attrs)
+
+	cluster := mapString(resourceAttrs, "k8s.cluster.name")
+	switch {
+	case cluster != "":
+		event.Node = cluster
+	case event.Node == "":
+		event.Node = ev.host
+	}
+
+	for k, v := range map[string]string{
+		"k8s.event.reason":   ev.reason,
+		"k8s.event.type":     ev.eventType,
+		"k8s.object.kind":    ev.kind,
+		"k8s.object.name":    ev.name,
+		"k8s.object.uid":     ev.uid,
+		"k8s.namespace.name": ev.namespace,
+		"k8s.node.name":      ev.host,
+	} {
+		if v != "" {
+			event.AdditionalInfo[m.mapper.additionalInfoKey(k)] = v
+		}
+	}
+
+	key := ev.uid
+	if key == "" {
+		key = ev.namespace + "/" + ev.kind + "/" + ev.name
+	}
+	event.MessageKey = key + "/" + ev.reason
+}
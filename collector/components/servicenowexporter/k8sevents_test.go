@@ -0,0 +1,138 @@
+package servicenowexporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// createK8sEventLogs returns a Kubernetes event as collected by the k8s_events receiver.
+func createK8sEventLogs(eventType string, reason string) plog.Logs {
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("k8s.cluster.name", "prod")
+	rl.Resource().Attributes().PutStr("k8s.node.name", "node-1")
+	rl.Resource().Attributes().PutStr("k8s.object.kind", "Pod")
+	rl.Resource().Attributes().PutStr("k8s.object.name", "cart-0")
+	rl.Resource().Attributes().PutStr("k8s.object.uid", "6b2f0c4e")
+	log := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	log.SetSeverityNumber(plog.SeverityNumberWarn)
+	log.SetSeverityText(eventType)
+	log.Body().SetStr("Back-off restarting failed container")
+	log.Attributes().PutStr("k8s.event.reason", reason)
+	log.Attributes().PutStr("k8s.namespace.name", "shop")
+	return ld
+}
+
+// createK8sObjectLogs returns a Kubernetes event as collected by the k8sobjects receiver in watch mode.
+func createK8sObjectLogs(watchType string) plog.Logs {
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("k8s.namespace.name", "shop")
+	log := rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty()
+	log.Attributes().PutStr("k8s.resource.name", "events")
+	_ = log.Body().SetEmptyMap().FromRaw(map[string]any{
+		"type": watchType,
+		"object": map[string]any{
+			"type":    "Warning",
+			"reason":  "FailedScheduling",
+			"message": "0/3 nodes are available",
+			"involvedObject": map[string]any{
+				"kind":      "Pod",
+				"name":      "cart-1",
+				"namespace": "shop",
+				"uid":       "9d1e7a2b",
+			},
+		},
+	})
+	return ld
+}
+
+func createK8sEventsConfig() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.KubernetesEvents.Enabled = true
+	return cfg
+}
+
+func TestK8sEventsMapping(t *testing.T) {
+	producer := newTestProducer(t, createK8sEventsConfig())
+
+	events := convertTestLogs(t, producer, createK8sEventLogs(k8sEventTypeWarning, "BackOff"))
+	require.Len(t, events, 1)
+	event := events[0]
+	assert.Equal(t, "BackOff", event.Type)
+	assert.Equal(t, "Back-off restarting failed container", event.Description)
+	assert.Equal(t, severityMajor, event.Severity)
+	assert.Equal(t, "Pod/cart-0", event.Resource)
+	assert.Equal(t, "prod", event.Node)
+	assert.Equal(t, "6b2f0c4e/BackOff", event.MessageKey)
+	assert.Equal(t, "shop", event.AdditionalInfo["k8s_namespace_name"])
+	assert.Equal(t, "prod", event.AdditionalInfo["k8s_cluster_name"])
+	assert.Equal(t, "Warning", event.AdditionalInfo["k8s_event_type"])
+
+	// Repeated events of a crash loop update the same alert.
+	events = convertTestLogs(t, producer, createK8sEventLogs(k8sEventTypeWarning, "BackOff"))
+	require.Len(t, events, 1)
+	assert.Equal(t, event.MessageKey, events[0].MessageKey)
+
+	events = convertTestLogs(t, producer, createK8sEventLogs(k8sEventTypeWarning, "ImagePullBackOff"))
+	require.Len(t, events, 1)
+	assert.Equal(t, severityWarning, events[0].Severity)
+
+	events = convertTestLogs(t, producer, createK8sEventLogs(k8sEventTypeNormal, "Pulled"))
+	require.Len(t, events, 1)
+	assert.Equal(t, severityInfo, events[0].Severity)
+}
+
+func TestK8sEventsConfig(t *testing.T) {
+	cfg := createK8sEventsConfig()
+	cfg.KubernetesEvents.ReasonSeverities = map[string]string{"BackOff": severityCritical}
+	cfg.KubernetesEvents.WarningSeverity = severityMinor
+	cfg.Events.MessageKey = "${k8s.object.name}"
+	producer := newTestProducer(t, cfg)
+
+	events := convertTestLogs(t, producer, createK8sEventLogs(k8sEventTypeWarning, "BackOff"))
+	require.Len(t, events, 1)
+	assert.Equal(t, severityCritical, events[0].Severity)
+	assert.Equal(t, "cart-0", events[0].MessageKey)
+
+	events = convertTestLogs(t, producer, createK8sEventLogs(k8sEventTypeWarning, "ImagePullBackOff"))
+	require.Len(t, events, 1)
+	assert.Equal(t, severityMinor, events[0].Severity)
+
+	// The scope name and body are used when disabled, the default.
+	events = convertTestLogs(t, newTestProducer(t, createDefaultConfig().(*Config)), createK8sEventLogs(k8sEventTypeWarning, "BackOff"))
+	require.Len(t, events, 1)
+	assert.Equal(t, "", events[0].Type)
+	assert.Equal(t, severityWarning, events[0].Severity)
+	assert.Empty(t, events[0].MessageKey)
+}
+
+func TestK8sObjectsEventsMapping(t *testing.T) {
+	producer := newTestProducer(t, createK8sEventsConfig())
+
+	events := convertTestLogs(t, producer, createK8sObjectLogs("ADDED"))
+	require.Len(t, events, 1)
+	assert.Equal(t, "FailedScheduling", events[0].Type)
+	assert.Equal(t, "0/3 nodes are available", events[0].Description)
+	assert.Equal(t, severityMinor, events[0].Severity)
+	assert.Equal(t, "Pod/cart-1", events[0].Resource)
+	assert.Equal(t, "9d1e7a2b/FailedScheduling", events[0].MessageKey)
+	assert.Equal(t, "Pod", events[0].AdditionalInfo["k8s_object_kind"])
+
+	// The deletion of the event object is not an event.
+	assert.Empty(t, convertTestLogs(t, producer, createK8sObjectLogs("DELETED")))
+}
+
+func TestK8sEventsConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.KubernetesEvents.ReasonSeverities = map[string]string{"BackOff": "7"}
+	assert.Error(t, component.ValidateConfig(cfg))
+
+	cfg.KubernetesEvents.ReasonSeverities = nil
+	cfg.KubernetesEvents.WarningSeverity = "critical"
+	assert.Error(t, component.ValidateConfig(cfg))
+}
@@ -0,0 +1,264 @@
+package servicenowexporter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+// defaultTemplateKey expands to the value the exporter uses when no template is configured.
+const defaultTemplateKey = "default"
+
+// MappingConfig defines how the node, resource and resource_path fields are derived from attributes.
+//
+// Templates contain ${key} placeholders replaced by the value of the attribute key, looked up in the
+// log record or data point attributes first and then in the resource attributes. ${default} expands
+// to the value used when there is no template. Ex: "${k8s.namespace.name}/${k8s.pod.name}"
+type MappingConfig struct {
+	// NodeAttributes are the resource attributes used as node, in order. The first one present is used.
+	NodeAttributes []string `mapstructure:"node_attributes"`
+
+	// Node is an optional template for the node of events, logs and metrics, using resource attributes only
+	Node string `mapstructure:"node"`
+
+	// Resource is an optional template for the resource of events
+	Resource string `mapstructure:"resource"`
+
+	// ResourcePath is an optional template for the resource_path of logs and metrics. Histogram bucket
+	// and summary quantile tags are appended to it.
+	ResourcePath string `mapstructure:"resource_path"`
+
+	// KeySeparator joins the keys of nested map attributes, and the indexes of slice attributes, when
+	// they are flattened. Ex: {"k8s": {"pod": {"name": "a"}}} becomes k8s.pod.name=a by default
+	KeySeparator string `mapstructure:"key_separator"`
+
+	// AdditionalInfoKeySeparator replaces the dots of the keys in the additional_info of events. Default: _
+	AdditionalInfoKeySeparator string `mapstructure:"additional_info_key_separator"`
+}
+
+func (cfg *MappingConfig) Validate() error {
+	for _, t := range []string{cfg.Node, cfg.Resource, cfg.ResourcePath} {
+		if _, err := parseAttributeTemplate(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attributeMapper applies the MappingConfig.
+type attributeMapper struct {
+	keySeparator               string
+	additionalInfoKeySeparator string
+	nodeAttributes             []string
+	node                       *attributeTemplate
+	resource                   *attributeTemplate
+	resourcePath               *attributeTemplate
+}
+
+// newAttributeMapper returns an error for an invalid template.
+func newAttributeMapper(cfg MappingConfig) (*attributeMapper, error) {
+	m := &attributeMapper{
+		keySeparator:               cfg.KeySeparator,
+		additionalInfoKeySeparator: cfg.AdditionalInfoKeySeparator,
+		nodeAttributes:             cfg.NodeAttributes,
+	}
+	if m.keySeparator == "" {
+		m.keySeparator = "."
+	}
+	if m.additionalInfoKeySeparator == "" {
+		m.additionalInfoKeySeparator = "_"
+	}
+	var err error
+	if m.node, err = parseAttributeTemplate(cfg.Node); err != nil {
+		return nil, err
+	}
+	if m.resource, err = parseAttributeTemplate(cfg.Resource); err != nil {
+		return nil, err
+	}
+	if m.resourcePath, err = parseAttributeTemplate(cfg.ResourcePath); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// formatNode returns the node for the given resource attributes.
+func (m *attributeMapper) formatNode(resourceAttrs map[string]string) string {
+	node := ""
+	for _, key := range m.nodeAttributes {
+		if v := resourceAttrs[key]; v != "" {
+			node = v
+			break
+		}
+	}
+	if m.node == nil {
+		return node
+	}
+	return m.node.render(node, resourceAttrs)
+}
+
+// formatResource returns the resource of an event, defaulting to defaultResource.
+func (m *attributeMapper) formatResource(defaultResource string, resourceAttrs pcommon.Map, attrs pcommon.Map) string {
+	if m.resource == nil {
+		return defaultResource
+	}
+	return m.resource.render(defaultResource, m.ci2metricAttrs(attrs), m.ci2metricAttrs(resourceAttrs))
+}
+
+// formatResourcePath returns the resource_path of a log or metric, defaulting to defaultPath.
+func (m *attributeMapper) formatResourcePath(defaultPath string, resourceAttrs pcommon.Map, attrs pcommon.Map) string {
+	if m.resourcePath == nil {
+		return defaultPath
+	}
+	return m.resourcePath.render(defaultPath, m.ci2metricAttrs(attrs), m.ci2metricAttrs(resourceAttrs))
+}
+
+// flatten calls fn with the string value of every attribute, in order. Nested maps and slices are
+// flattened recursively, joining the keys and slice indexes with the key separator. Empty values,
+// maps and slices are skipped.
+func (m *attributeMapper) flatten(attrs pcommon.Map, fn func(k string, v string)) {
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		m.flattenValue(k, v, fn)
+		return true
+	})
+}
+
+func (m *attributeMapper) flattenValue(key string, v pcommon.Value, fn func(k string, v string)) {
+	switch v.Type() {
+	case pcommon.ValueTypeEmpty:
+	case pcommon.ValueTypeMap:
+		v.Map().Range(func(k string, v pcommon.Value) bool {
+			m.flattenValue(key+m.keySeparator+k, v, fn)
+			return true
+		})
+	case pcommon.ValueTypeSlice:
+		for i := 0; i < v.Slice().Len(); i++ {
+			m.flattenValue(key+m.keySeparator+strconv.Itoa(i), v.Slice().At(i), fn)
+		}
+	default:
+		fn(key, v.AsString())
+	}
+}
+
+// ci2metricAttrs converts attributes to a map of string key/value pairs
+// for use in ci2metric_id in the push metric API
+func (m *attributeMapper) ci2metricAttrs(rAttrs pcommon.Map) map[string]string {
+	attrs := make(map[string]string, rAttrs.Len())
+	m.flatten(rAttrs, func(k string, v string) {
+		attrs[k] = v
+	})
+	return attrs
+}
+
+// buildPath is used to build the <metric_path>: the name followed by a tag per attribute.
+func (m *attributeMapper) buildPath(name string, attributes pcommon.Map) string {
+	if attributes.Len() == 0 {
+		return name
+	}
+
+	buf := new(bytes.Buffer)
+
+	buf.WriteString(name)
+	m.flatten(attributes, func(k string, value string) {
+		if value == "" {
+			value = tagValueEmptyPlaceholder
+		}
+		buf.WriteString(tagPrefix)
+		buf.WriteString(sanitizeTagKey(k))
+		buf.WriteString(tagKeyValueSeparator)
+		buf.WriteString(value)
+	})
+
+	return buf.String()
+}
+
+func (m *attributeMapper) formatAdditionalInfo(attrs map[string]string, resourceAttrs map[string]string) (map[string]string, error) {
+	// merge attrs + resource attrs
+	newAttrs := make(map[string]string)
+	for k, v := range resourceAttrs {
+		if v == "" {
+			continue
+		}
+		newAttrs[m.additionalInfoKey(k)] = v
+	}
+
+	for k, v := range attrs {
+		if v == "" {
+			continue
+		}
+		newAttrs[m.additionalInfoKey(k)] = v
+	}
+
+	return newAttrs, nil
+}
+
+// additionalInfoKey replaces the dots of an attribute key with the additional_info key separator.
+func (m *attributeMapper) additionalInfoKey(k string) string {
+	return strings.ReplaceAll(k, ".", m.additionalInfoKeySeparator)
+}
+
+// attributeTemplate is a string with ${key} placeholders.
+type attributeTemplate struct {
+	parts []templatePart
+}
+
+type templatePart struct {
+	literal string
+	key     string
+}
+
+// parseAttributeTemplate returns a nil template for an empty string.
+func parseAttributeTemplate(s string) (*attributeTemplate, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	t := &attributeTemplate{}
+	for len(s) > 0 {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			t.parts = append(t.parts, templatePart{literal: s})
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{literal: s[:start]})
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("mapping: unterminated placeholder in template %q", s)
+		}
+		key := s[start+2 : start+end]
+		if key == "" {
+			return nil, errors.New("mapping: empty placeholder in template")
+		}
+		t.parts = append(t.parts, templatePart{key: key})
+		s = s[start+end+1:]
+	}
+	return t, nil
+}
+
+// render expands the template, looking up each key in attrs in order.
+// Keys not found expand to an empty string.
+func (t *attributeTemplate) render(defaultValue string, attrs ...map[string]string) string {
+	var sb strings.Builder
+	for _, p := range t.parts {
+		if p.key == "" {
+			sb.WriteString(p.literal)
+			continue
+		}
+		if p.key == defaultTemplateKey {
+			sb.WriteString(defaultValue)
+			continue
+		}
+		for _, a := range attrs {
+			if v, ok := a[p.key]; ok {
+				sb.WriteString(v)
+				break
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,188 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// newTestAttributeMapper returns the mapper of a valid config.
+func newTestAttributeMapper(t *testing.T, cfg MappingConfig) *attributeMapper {
+	mapper, err := newAttributeMapper(cfg)
+	require.NoError(t, err)
+	return mapper
+}
+
+func TestFormatNode(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MappingConfig
+		attrs  map[string]string
+		want   string
+	}{
+		{
+			name:   "default",
+			config: createDefaultConfig().(*Config).Mapping,
+			attrs:  map[string]string{"host.name": "host-1", "k8s.node.name": "node-1"},
+			want:   "host-1",
+		},
+		{
+			name:   "first attribute present",
+			config: MappingConfig{NodeAttributes: []string{"k8s.node.name", "host.name", "service.instance.id"}},
+			attrs:  map[string]string{"host.name": "host-1", "service.instance.id": "abc"},
+			want:   "host-1",
+		},
+		{
+			name:   "none present",
+			config: MappingConfig{NodeAttributes: []string{"k8s.node.name"}},
+			attrs:  map[string]string{"host.name": "host-1"},
+			want:   "",
+		},
+		{
+			name:   "template",
+			config: MappingConfig{NodeAttributes: []string{"host.name"}, Node: "${cloud.region}/${default}"},
+			attrs:  map[string]string{"host.name": "host-1", "cloud.region": "us-east-1"},
+			want:   "us-east-1/host-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newTestAttributeMapper(t, tt.config).formatNode(tt.attrs))
+		})
+	}
+}
+
+func TestFormatResourcePathTemplate(t *testing.T) {
+	m := newTestAttributeMapper(t, MappingConfig{ResourcePath: "${k8s.namespace.name}/${k8s.pod.name}:${default}"})
+
+	resourceAttrs := pcommon.NewMap()
+	resourceAttrs.PutStr("k8s.namespace.name", "shop")
+	resourceAttrs.PutStr("k8s.pod.name", "resource-pod")
+	attrs := pcommon.NewMap()
+	// Data point attributes take precedence over resource attributes.
+	attrs.PutStr("k8s.pod.name", "cart-0")
+
+	assert.Equal(t, "shop/cart-0:cpu", m.formatResourcePath("cpu", resourceAttrs, attrs))
+	assert.Equal(t, "cpu", newTestAttributeMapper(t, MappingConfig{}).formatResourcePath("cpu", resourceAttrs, attrs))
+}
+
+func TestMappingConfigValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Mapping.Resource = "${service.name}-${service.namespace}"
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.Mapping.Resource = "${service.name"
+	assert.EqualError(t, component.ValidateConfig(cfg), `mapping: unterminated placeholder in template "${service.name"`)
+
+	cfg.Mapping.Resource = "${}"
+	assert.EqualError(t, component.ValidateConfig(cfg), "mapping: empty placeholder in template")
+
+	// The exporter isn't created with a template it can't parse.
+	_, err := newServiceNowProducer(componenttest.NewNopTelemetrySettings(), cfg)
+	assert.EqualError(t, err, "mapping: empty placeholder in template")
+}
+
+func TestMappingAppliedToEventsAndMetrics(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Mapping = MappingConfig{
+		NodeAttributes: []string{"k8s.node.name", "host.name"},
+		Resource:       "${k8s.deployment.name}",
+		ResourcePath:   "${k8s.deployment.name}/${default}",
+	}
+	producer := newTestProducer(t, cfg)
+
+	ld := plog.NewLogs()
+	rl := ld.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("host.name", "host-1")
+	rl.Resource().Attributes().PutStr("k8s.node.name", "node-1")
+	rl.Resource().Attributes().PutStr("k8s.deployment.name", "cart")
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("hello")
+	require.NoError(t, exportRequest(producer.convertLogs(context.Background(), ld)))
+
+	events := mid.received("/events")
+	require.Len(t, events, 1)
+	batch := decodeEventBatch(t, events[0])
+	require.Len(t, batch, 1)
+	assert.Equal(t, "node-1", batch[0].Node)
+	assert.Equal(t, "cart", batch[0].Resource)
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rl.Resource().Attributes().CopyTo(rm.Resource().Attributes())
+	metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("cpu")
+	dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+	dp.SetDoubleValue(0.5)
+	dp.Attributes().PutStr("state", "idle")
+	require.NoError(t, exportRequest(producer.convertMetrics(context.Background(), md)))
+
+	payloads := mid.received("/metrics")
+	require.Len(t, payloads, 1)
+	var metrics []ServiceNowMetric
+	require.NoError(t, json.Unmarshal(payloads[0], &metrics))
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "node-1", metrics[0].Node)
+	assert.Equal(t, "cart/cpu;state=idle", metrics[0].ResourcePath)
+}
+
+func createNonStringAttributes() pcommon.Map {
+	attrs := pcommon.NewMap()
+	attrs.PutStr("http.method", "GET")
+	attrs.PutInt("http.status_code", 503)
+	attrs.PutBool("retry", true)
+	attrs.PutDouble("ratio", 0.5)
+	attrs.PutEmpty("ignored")
+	k8s := attrs.PutEmptyMap("k8s")
+	pod := k8s.PutEmptyMap("pod")
+	pod.PutStr("name", "cart-0")
+	pod.PutInt("restart_count", 2)
+	tags := attrs.PutEmptySlice("tags")
+	tags.AppendEmpty().SetStr("a")
+	tags.AppendEmpty().SetStr("b")
+	return attrs
+}
+
+func TestBuildPathNonStringAttributes(t *testing.T) {
+	attrs := createNonStringAttributes()
+
+	assert.Equal(t,
+		"requests;http.method=GET;http.status_code=503;retry=true;ratio=0.5;k8s.pod.name=cart-0;k8s.pod.restart_count=2;tags.0=a;tags.1=b",
+		newTestAttributeMapper(t, createDefaultConfig().(*Config).Mapping).buildPath("requests", attrs))
+	assert.Equal(t,
+		"requests;http.method=GET;http.status_code=503;retry=true;ratio=0.5;k8s/pod/name=cart-0;k8s/pod/restart_count=2;tags/0=a;tags/1=b",
+		newTestAttributeMapper(t, MappingConfig{KeySeparator: "/"}).buildPath("requests", attrs))
+}
+
+func TestCI2MetricAttrsAndAdditionalInfoNonStringAttributes(t *testing.T) {
+	m := newTestAttributeMapper(t, createDefaultConfig().(*Config).Mapping)
+	attrs := m.ci2metricAttrs(createNonStringAttributes())
+	assert.Equal(t, map[string]string{
+		"http.method":           "GET",
+		"http.status_code":      "503",
+		"retry":                 "true",
+		"ratio":                 "0.5",
+		"k8s.pod.name":          "cart-0",
+		"k8s.pod.restart_count": "2",
+		"tags.0":                "a",
+		"tags.1":                "b",
+	}, attrs)
+
+	info, err := m.formatAdditionalInfo(attrs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "503", info["http_status_code"])
+	assert.Equal(t, "2", info["k8s_pod_restart_count"])
+
+	m = newTestAttributeMapper(t, MappingConfig{AdditionalInfoKeySeparator: "-"})
+	info, err = m.formatAdditionalInfo(attrs, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2", info["k8s-pod-restart_count"])
+}
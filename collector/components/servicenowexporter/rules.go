@@ -0,0 +1,343 @@
+package servicenowexporter
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// Severity of the events sent by the threshold rules without a severity (3 = Minor).
+const defaultRuleSeverity = severityMinor
+
+// How long the series of a rule without max_staleness are kept once no longer received.
+const defaultRuleMaxStaleness = time.Hour
+
+// ThresholdRule sends an event when the data points of a gauge or sum metric breach a threshold
+// for a duration, and a Clear event once they no longer do. Each series, i.e. each combination of
+// resource and data point attributes, is evaluated on its own. The events are sent to instance_events_url.
+//
+// The rules are evaluated on the received metrics, before the metrics_filter and the cumulative
+// sums conversion are applied. A breaching series not received for the max_staleness of its rule
+// is forgotten, without a Clear event. The state of a series only changes once its event is sent, the
+// event being sent again by the next data point otherwise.
+type ThresholdRule struct {
+	// Name identifies the rule, it is the type of the events sent
+	Name string `mapstructure:"name"`
+
+	// Metric is a glob pattern matching the metric names. Ex: system.cpu.*
+	Metric string `mapstructure:"metric"`
+
+	// Attributes select the series whose data point or resource attributes have the given values
+	Attributes map[string]string `mapstructure:"attributes"`
+
+	// Operator compares the data point values to the threshold: >, >=, <, <=, == or !=
+	Operator string `mapstructure:"operator"`
+
+	Threshold float64 `mapstructure:"threshold"`
+
+	// For is how long the threshold must be breached before the event is sent, based on the data
+	// point timestamps. The event is sent on the first breaching data point when 0.
+	For time.Duration `mapstructure:"for"`
+
+	// Severity of the event sent, Minor (3) by default
+	Severity string `mapstructure:"severity"`
+
+	// MessageKey is a template of the message_key of the events, using the data point and resource
+	// attributes, ${default} expands to the resource path of the series. By default, the events
+	// message_key or the rule name followed by the node and resource path of the series.
+	MessageKey string `mapstructure:"message_key"`
+
+	// Description is a template of the description of the events. ${default} expands to a description
+	// of the breach. Ex: "High CPU on ${host.name}: ${default}"
+	Description string `mapstructure:"description"`
+
+	// MaxStaleness is how long a breaching series is kept once no longer received, 1h by default
+	MaxStaleness time.Duration `mapstructure:"max_staleness"`
+}
+
+func validateRules(rules []ThresholdRule) error {
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return errors.New("rules: name must be specified")
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rules: duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		if _, err := path.Match(rule.Metric, ""); err != nil || rule.Metric == "" {
+			return fmt.Errorf("rules: invalid metric pattern %q in rule %q", rule.Metric, rule.Name)
+		}
+		if _, ok := ruleOperators[rule.Operator]; !ok {
+			return fmt.Errorf("rules: unsupported operator %q in rule %q, must be >, >=, <, <=, == or !=", rule.Operator, rule.Name)
+		}
+		if rule.For < 0 {
+			return fmt.Errorf("rules: for must not be negative in rule %q", rule.Name)
+		}
+		if rule.MaxStaleness < 0 {
+			return fmt.Errorf("rules: max_staleness must not be negative in rule %q", rule.Name)
+		}
+		if rule.Severity != "" {
+			if err := validateSeverity(rule.Severity); err != nil || rule.Severity == severityClear {
+				return fmt.Errorf("rules: invalid severity %q in rule %q, must be between 1 and 5", rule.Severity, rule.Name)
+			}
+		}
+		for _, t := range []string{rule.MessageKey, rule.Description} {
+			if _, err := parseAttributeTemplate(t); err != nil {
+				return fmt.Errorf("rules: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+var ruleOperators = map[string]func(value float64, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// thresholdRule is a ThresholdRule with its templates parsed.
+type thresholdRule struct {
+	ThresholdRule
+	breached    func(value float64, threshold float64) bool
+	messageKey  *attributeTemplate
+	description *attributeTemplate
+}
+
+// breachState tracks a series breaching the threshold of a rule, or whose event is firing.
+type breachState struct {
+	// since is when the series started breaching the threshold, 0 when it no longer does
+	since        pcommon.Timestamp
+	firing       bool
+	lastSeen     time.Time
+	maxStaleness time.Duration
+}
+
+// breachChanges holds the series of the events of a request, firing (true) or cleared (false),
+// committed once the events are sent.
+type breachChanges map[string]bool
+
+// ruleEvaluator applies the threshold rules. It only keeps the state of the series breaching a
+// threshold or firing.
+type ruleEvaluator struct {
+	rules []thresholdRule
+	// sweepInterval is the shortest max_staleness of the rules
+	sweepInterval time.Duration
+	mapper        *attributeMapper
+	events        *eventFields
+
+	mu        sync.Mutex
+	breaches  map[string]*breachState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// newRuleEvaluator returns an error for an invalid rule.
+func newRuleEvaluator(rules []ThresholdRule, mapper *attributeMapper, events *eventFields) (*ruleEvaluator, error) {
+	if err := validateRules(rules); err != nil {
+		return nil, err
+	}
+	ev := &ruleEvaluator{
+		mapper:   mapper,
+		events:   events,
+		breaches: make(map[string]*breachState),
+		now:      time.Now,
+	}
+	for _, rule := range rules {
+		r := thresholdRule{ThresholdRule: rule, breached: ruleOperators[rule.Operator]}
+		if r.Severity == "" {
+			r.Severity = defaultRuleSeverity
+		}
+		if r.MaxStaleness == 0 {
+			r.MaxStaleness = defaultRuleMaxStaleness
+		}
+		if ev.sweepInterval == 0 || r.MaxStaleness < ev.sweepInterval {
+			ev.sweepInterval = r.MaxStaleness
+		}
+		// The templates were validated above.
+		r.messageKey, _ = parseAttributeTemplate(rule.MessageKey)
+		r.description, _ = parseAttributeTemplate(rule.Description)
+		ev.rules = append(ev.rules, r)
+	}
+	return ev, nil
+}
+
+// evaluate returns the events sent by the rules matching the metric, recording their series in changes.
+func (ev *ruleEvaluator) evaluate(m pmetric.Metric, rAttrs pcommon.Map, changes breachChanges) []ServiceNowEvent {
+	var dps pmetric.NumberDataPointSlice
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		dps = m.Gauge().DataPoints()
+	case pmetric.MetricTypeSum:
+		dps = m.Sum().DataPoints()
+	default:
+		return nil
+	}
+
+	var events []ServiceNowEvent
+	for i := range ev.rules {
+		rule := &ev.rules[i]
+		if ok, _ := path.Match(rule.Metric, m.Name()); !ok {
+			continue
+		}
+		for j := 0; j < dps.Len(); j++ {
+			dp := dps.At(j)
+			value, ok := numberValue(dp)
+			if !ok || !ev.selects(rule, rAttrs, dp.Attributes()) {
+				continue
+			}
+			if event, ok := ev.evaluatePoint(rule, m.Name(), rAttrs, dp, value, changes); ok {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+func (ev *ruleEvaluator) selects(rule *thresholdRule, rAttrs pcommon.Map, attrs pcommon.Map) bool {
+	if len(rule.Attributes) == 0 {
+		return true
+	}
+	dpAttrs, resourceAttrs := ev.mapper.ci2metricAttrs(attrs), ev.mapper.ci2metricAttrs(rAttrs)
+	for k, want := range rule.Attributes {
+		v, ok := dpAttrs[k]
+		if !ok {
+			v = resourceAttrs[k]
+		}
+		if v != want {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluatePoint updates the breach of the series and returns the event to send, if any. Whether the
+// series is firing only changes once the event is sent.
+func (ev *ruleEvaluator) evaluatePoint(rule *thresholdRule, metricName string, rAttrs pcommon.Map, dp pmetric.NumberDataPoint, value float64, changes breachChanges) (ServiceNowEvent, bool) {
+	key := rule.Name + "\x00" + seriesKey(metricName, rAttrs, dp.Attributes())
+
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	now := ev.now()
+	ev.sweep(now)
+
+	state, ok := ev.breaches[key]
+	firing := ok && state.firing
+	if f, changed := changes[key]; changed {
+		firing = f
+	}
+	if !rule.breached(value, rule.Threshold) {
+		if !ok {
+			return ServiceNowEvent{}, false
+		}
+		state.since = 0
+		state.lastSeen = now
+		if !firing {
+			if !state.firing {
+				delete(ev.breaches, key)
+			}
+			return ServiceNowEvent{}, false
+		}
+		changes[key] = false
+		description := fmt.Sprintf("%s is %s, no longer %s %s", metricName, formatFloatForLabel(value), rule.Operator, formatFloatForLabel(rule.Threshold))
+		return ev.createEvent(rule, metricName, rAttrs, dp, value, severityClear, description), true
+	}
+
+	if !ok {
+		state = &breachState{maxStaleness: rule.MaxStaleness}
+		ev.breaches[key] = state
+	}
+	if state.since == 0 {
+		state.since = dp.Timestamp()
+	}
+	state.lastSeen = now
+	if firing || dp.Timestamp().AsTime().Sub(state.since.AsTime()) < rule.For {
+		return ServiceNowEvent{}, false
+	}
+	changes[key] = true
+	description := fmt.Sprintf("%s is %s, %s %s", metricName, formatFloatForLabel(value), rule.Operator, formatFloatForLabel(rule.Threshold))
+	if rule.For > 0 {
+		description += " for " + rule.For.String()
+	}
+	return ev.createEvent(rule, metricName, rAttrs, dp, value, rule.Severity, description), true
+}
+
+// commit applies the changes of the events sent, so the series whose events were never sent send
+// them again.
+func (ev *ruleEvaluator) commit(changes breachChanges) {
+	if len(changes) == 0 {
+		return
+	}
+
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	for key, firing := range changes {
+		state, ok := ev.breaches[key]
+		if !ok {
+			// Forgotten since the event was built.
+			continue
+		}
+		state.firing = firing
+		if !firing && state.since == 0 {
+			delete(ev.breaches, key)
+		}
+	}
+}
+
+// sweep forgets the series not seen for the max_staleness of their rule, at most once per the
+// shortest max_staleness.
+func (ev *ruleEvaluator) sweep(now time.Time) {
+	if now.Sub(ev.lastSweep) < ev.sweepInterval {
+		return
+	}
+	ev.lastSweep = now
+	for key, s := range ev.breaches {
+		if now.Sub(s.lastSeen) >= s.maxStaleness {
+			delete(ev.breaches, key)
+		}
+	}
+}
+
+func (ev *ruleEvaluator) createEvent(rule *thresholdRule, metricName string, rAttrs pcommon.Map, dp pmetric.NumberDataPoint, value float64, severity string, description string) ServiceNowEvent {
+	dpAttrs, resourceAttrs := ev.mapper.ci2metricAttrs(dp.Attributes()), ev.mapper.ci2metricAttrs(rAttrs)
+	additionalInfo, _ := ev.mapper.formatAdditionalInfo(dpAttrs, resourceAttrs)
+	additionalInfo["rule"] = rule.Name
+	additionalInfo["value"] = formatFloatForLabel(value)
+	additionalInfo["threshold"] = formatFloatForLabel(rule.Threshold)
+
+	path := ev.mapper.formatResourcePath(ev.mapper.buildPath(metricName, dp.Attributes()), rAttrs, dp.Attributes())
+	if rule.description != nil {
+		description = rule.description.render(description, dpAttrs, resourceAttrs)
+	}
+	event := ServiceNowEvent{
+		Type:           rule.Name,
+		Description:    description,
+		Resource:       ev.mapper.formatResource(path, rAttrs, dp.Attributes()),
+		Severity:       severity,
+		Timestamp:      formatEventTimestamp(dp.Timestamp()),
+		Node:           ev.mapper.formatNode(resourceAttrs),
+		Source:         midSource,
+		AdditionalInfo: additionalInfo,
+	}
+	ev.events.set(&event, metricName, rAttrs, dp.Attributes())
+	if event.MetricName == "" {
+		event.MetricName = metricName
+	}
+	// The message key of the rule takes precedence over the one of the events config.
+	switch {
+	case rule.messageKey != nil:
+		event.MessageKey = rule.messageKey.render(path, dpAttrs, resourceAttrs)
+	case event.MessageKey == "":
+		event.MessageKey = rule.Name + "/" + event.Node + "/" + path
+	}
+	return event
+}
@@ -0,0 +1,197 @@
+package servicenowexporter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+var rulesStart = time.Unix(1700000000, 0)
+
+// createCPUMetrics returns a cpu gauge per host, with the given value at rulesStart + seconds.
+func createCPUMetrics(seconds int, values map[string]float64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	for host, value := range values {
+		rm := md.ResourceMetrics().AppendEmpty()
+		rm.Resource().Attributes().PutStr("host.name", host)
+		metric := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		metric.SetName("system.cpu.utilization")
+		dp := metric.SetEmptyGauge().DataPoints().AppendEmpty()
+		dp.SetTimestamp(pcommon.NewTimestampFromTime(rulesStart.Add(time.Duration(seconds) * time.Second)))
+		dp.Attributes().PutStr("state", "user")
+		dp.SetDoubleValue(value)
+	}
+	return md
+}
+
+func convertTestMetrics(t *testing.T, producer *serviceNowProducer, md pmetric.Metrics) *serviceNowRequest {
+	req, err := producer.convertMetrics(context.Background(), md)
+	require.NoError(t, err)
+	return req.(*serviceNowRequest)
+}
+
+// exportTestMetrics converts and exports the metrics, returning the request sent.
+func exportTestMetrics(t *testing.T, producer *serviceNowProducer, md pmetric.Metrics) *serviceNowRequest {
+	req := convertTestMetrics(t, producer, md)
+	require.NoError(t, req.Export(context.Background()))
+	return req
+}
+
+func TestThresholdRules(t *testing.T) {
+	cfg := newMidServerMock(t).config()
+	cfg.Rules = []ThresholdRule{{
+		Name:       "high-cpu",
+		Metric:     "system.cpu.*",
+		Attributes: map[string]string{"state": "user"},
+		Operator:   ">",
+		Threshold:  0.9,
+		For:        time.Minute,
+		Severity:   severityMajor,
+	}}
+	producer := newTestProducer(t, cfg)
+
+	// The threshold must be breached for a minute.
+	req := exportTestMetrics(t, producer, createCPUMetrics(0, map[string]float64{"host-1": 0.95, "host-2": 0.5}))
+	assert.Empty(t, req.Events)
+	assert.Len(t, req.Metrics, 2)
+	assert.Empty(t, exportTestMetrics(t, producer, createCPUMetrics(30, map[string]float64{"host-1": 0.95})).Events)
+
+	events := exportTestMetrics(t, producer, createCPUMetrics(60, map[string]float64{"host-1": 0.97})).Events
+	require.Len(t, events, 1)
+	event := events[0]
+	assert.Equal(t, "high-cpu", event.Type)
+	assert.Equal(t, severityMajor, event.Severity)
+	assert.Equal(t, "host-1", event.Node)
+	assert.Equal(t, "system.cpu.utilization", event.MetricName)
+	assert.Equal(t, "system.cpu.utilization;state=user", event.Resource)
+	assert.Equal(t, "high-cpu/host-1/system.cpu.utilization;state=user", event.MessageKey)
+	assert.Equal(t, "system.cpu.utilization is 0.97, > 0.9 for 1m0s", event.Description)
+	assert.Equal(t, "0.97", event.AdditionalInfo["value"])
+	assert.Equal(t, "0.9", event.AdditionalInfo["threshold"])
+
+	// The event is only sent once while the series keeps breaching the threshold.
+	assert.Empty(t, exportTestMetrics(t, producer, createCPUMetrics(90, map[string]float64{"host-1": 0.99})).Events)
+
+	events = exportTestMetrics(t, producer, createCPUMetrics(120, map[string]float64{"host-1": 0.4})).Events
+	require.Len(t, events, 1)
+	assert.Equal(t, severityClear, events[0].Severity)
+	assert.Equal(t, event.MessageKey, events[0].MessageKey)
+	assert.Equal(t, "system.cpu.utilization is 0.4, no longer > 0.9", events[0].Description)
+}
+
+func TestThresholdRuleShortBreach(t *testing.T) {
+	cfg := newMidServerMock(t).config()
+	cfg.Rules = []ThresholdRule{{
+		Name:        "high-cpu",
+		Metric:      "system.cpu.utilization",
+		Operator:    ">=",
+		Threshold:   0.9,
+		For:         time.Minute,
+		MessageKey:  "cpu-${host.name}",
+		Description: "High CPU on ${host.name}: ${default}",
+	}}
+	producer := newTestProducer(t, cfg)
+
+	// A breach shorter than the duration neither sends an event nor a Clear event.
+	assert.Empty(t, exportTestMetrics(t, producer, createCPUMetrics(0, map[string]float64{"host-1": 0.9})).Events)
+	assert.Empty(t, exportTestMetrics(t, producer, createCPUMetrics(30, map[string]float64{"host-1": 0.1})).Events)
+	assert.Empty(t, exportTestMetrics(t, producer, createCPUMetrics(60, map[string]float64{"host-1": 0.9})).Events)
+
+	events := exportTestMetrics(t, producer, createCPUMetrics(120, map[string]float64{"host-1": 0.9})).Events
+	require.Len(t, events, 1)
+	assert.Equal(t, defaultRuleSeverity, events[0].Severity)
+	assert.Equal(t, "cpu-host-1", events[0].MessageKey)
+	assert.Equal(t, "High CPU on host-1: system.cpu.utilization is 0.9, >= 0.9 for 1m0s", events[0].Description)
+}
+
+func TestThresholdRuleStaleBreaches(t *testing.T) {
+	cfg := newMidServerMock(t).config()
+	cfg.Rules = []ThresholdRule{{Name: "high-cpu", Metric: "system.cpu.utilization", Operator: ">", Threshold: 0.9, MaxStaleness: time.Minute}}
+	producer := newTestProducer(t, cfg)
+	now := rulesStart
+	producer.rules.now = func() time.Time { return now }
+
+	require.Len(t, exportTestMetrics(t, producer, createCPUMetrics(0, map[string]float64{"host-1": 0.95, "host-2": 0.95})).Events, 2)
+	assert.Len(t, producer.rules.breaches, 2)
+
+	// host-2 stops reporting while breaching the threshold, its breach is forgotten.
+	now = now.Add(30 * time.Second)
+	exportTestMetrics(t, producer, createCPUMetrics(30, map[string]float64{"host-1": 0.95}))
+	now = now.Add(40 * time.Second)
+	exportTestMetrics(t, producer, createCPUMetrics(70, map[string]float64{"host-1": 0.95}))
+	assert.Len(t, producer.rules.breaches, 1)
+
+	// An event is sent again when it comes back, while host-1 is still firing.
+	events := exportTestMetrics(t, producer, createCPUMetrics(80, map[string]float64{"host-1": 0.95, "host-2": 0.95})).Events
+	require.Len(t, events, 1)
+	assert.Equal(t, "host-2", events[0].Node)
+}
+
+func TestThresholdRuleEventsNotSent(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Rules = []ThresholdRule{{Name: "high-cpu", Metric: "system.cpu.utilization", Operator: ">", Threshold: 0.9}}
+	producer := newTestProducer(t, cfg)
+
+	// The series is not firing until its event is sent.
+	mid.down.Store(true)
+	req := convertTestMetrics(t, producer, createCPUMetrics(0, map[string]float64{"host-1": 0.95}))
+	require.Len(t, req.Events, 1)
+	require.Error(t, req.Export(context.Background()))
+	mid.down.Store(false)
+	events := exportTestMetrics(t, producer, createCPUMetrics(30, map[string]float64{"host-1": 0.95})).Events
+	require.Len(t, events, 1)
+	assert.Equal(t, defaultRuleSeverity, events[0].Severity)
+	assert.Empty(t, exportTestMetrics(t, producer, createCPUMetrics(60, map[string]float64{"host-1": 0.95})).Events)
+
+	// Nor cleared until its Clear event is.
+	mid.down.Store(true)
+	req = convertTestMetrics(t, producer, createCPUMetrics(90, map[string]float64{"host-1": 0.4}))
+	require.Len(t, req.Events, 1)
+	require.Error(t, req.Export(context.Background()))
+	mid.down.Store(false)
+	events = exportTestMetrics(t, producer, createCPUMetrics(120, map[string]float64{"host-1": 0.4})).Events
+	require.Len(t, events, 1)
+	assert.Equal(t, severityClear, events[0].Severity)
+	assert.Empty(t, exportTestMetrics(t, producer, createCPUMetrics(150, map[string]float64{"host-1": 0.4})).Events)
+	assert.Empty(t, producer.rules.breaches)
+}
+
+func TestThresholdRulesSentAsEvents(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Rules = []ThresholdRule{{Name: "high-cpu", Metric: "system.cpu.utilization", Operator: ">", Threshold: 0.9}}
+	producer := newTestProducer(t, cfg)
+
+	require.NoError(t, exportRequest(producer.convertMetrics(context.Background(), createCPUMetrics(0, map[string]float64{"host-1": 0.95}))))
+	events := mid.received("/events")
+	require.Len(t, events, 1)
+	batch := decodeEventBatch(t, events[0])
+	require.Len(t, batch, 1)
+	assert.Equal(t, "high-cpu", batch[0].Type)
+	assert.Len(t, mid.received("/metrics"), 1)
+}
+
+func TestThresholdRulesValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Rules = []ThresholdRule{{Name: "high-cpu", Metric: "system.cpu.*", Operator: ">", Threshold: 0.9}}
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.Rules = append(cfg.Rules, ThresholdRule{Name: "high-cpu", Metric: "system.cpu.*", Operator: ">"})
+	assert.EqualError(t, component.ValidateConfig(cfg), `rules: duplicate rule name "high-cpu"`)
+
+	cfg.Rules = []ThresholdRule{{Name: "high-cpu", Metric: "system.cpu.*", Operator: "=>"}}
+	assert.EqualError(t, component.ValidateConfig(cfg), `rules: unsupported operator "=>" in rule "high-cpu", must be >, >=, <, <=, == or !=`)
+
+	cfg.Rules = []ThresholdRule{{Name: "high-cpu", Metric: "system.cpu.*", Operator: ">", Severity: severityClear}}
+	assert.EqualError(t, component.ValidateConfig(cfg), `rules: invalid severity "0" in rule "high-cpu", must be between 1 and 5`)
+
+	cfg.Rules = []ThresholdRule{{Name: "high-cpu", Metric: "system.cpu.*", Operator: ">", MaxStaleness: -time.Minute}}
+	assert.EqualError(t, component.ValidateConfig(cfg), `rules: max_staleness must not be negative in rule "high-cpu"`)
+}
@@ -1,16 +1,20 @@
 package servicenowexporter
 
 import (
-	"bytes"
 	"context"
 	"strconv"
 	"strings"
 
 	"go.uber.org/zap"
 
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 	"go.opentelemetry.io/collector/pdata/pcommon"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+
+	"github.com/lightstep/sn-collector/collector/servicenowexporter/internal/metadata"
 )
 
 const (
@@ -41,25 +45,90 @@ const (
 	infinityCarbonValue = "inf"
 
 	midSource = "sn-otel-collector"
+
+	// ciSysIDAttribute is the resource attribute binding data directly to a CI.
+	ciSysIDAttribute = "servicenow.ci.sys_id"
+
+	// Severity used for events created from spans with an error status (3 = Minor).
+	spanErrorSeverity = "3"
+
+	// Metric types used for the RED metrics derived from spans.
+	spanRequestsMetricType = "span.requests"
+	spanErrorsMetricType   = "span.errors"
+	spanDurationMetricType = "span.duration"
+	spanNameTagKey         = "span.name"
 )
 
 type serviceNowProducer struct {
-	logger *zap.Logger
-	config *Config
-	client *midClient
+	logger      *zap.Logger
+	settings    component.TelemetrySettings
+	config      *Config
+	client      *midClient
+	severities  *severityMapper
+	mapper      *attributeMapper
+	cumulative  *cumulativeConverter
+	filter      *metricFilter
+	cardinality *cardinalityLimiter
+	telemetry   *metadata.TelemetryBuilder
+	events      *eventFields
+	rules       *ruleEvaluator
+	k8sEvents   *k8sEventMapper
 }
 
-func newServiceNowProducer(logger *zap.Logger, config *Config) *serviceNowProducer {
+func newServiceNowProducer(settings component.TelemetrySettings, config *Config) (*serviceNowProducer, error) {
+	filter, err := newMetricFilter(config.MetricsFilter)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := newAttributeMapper(config.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	events, err := newEventFields(config.Events, mapper)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := newRuleEvaluator(config.Rules, mapper, events)
+	if err != nil {
+		return nil, err
+	}
 	return &serviceNowProducer{
-		logger: logger,
-		config: config,
-		client: newMidClient(config, logger),
+		logger:     settings.Logger,
+		settings:   settings,
+		config:     config,
+		severities: newSeverityMapper(config.SeverityMapping),
+		mapper:     mapper,
+		cumulative: newCumulativeConverter(config.CumulativeSums),
+		filter:     filter,
+		events:     events,
+		rules:      rules,
+		k8sEvents:  newK8sEventMapper(config.KubernetesEvents, mapper),
+	}, nil
+}
+
+// start creates the MID client, which needs the host to resolve any configured extensions,
+// and the components reporting internal telemetry.
+func (e *serviceNowProducer) start(ctx context.Context, host component.Host) error {
+	telemetry, err := metadata.NewTelemetryBuilder(e.settings)
+	if err != nil {
+		return err
 	}
+	e.telemetry = telemetry
+
+	client, err := newMidClient(ctx, host, e.settings, telemetry, e.config)
+	if err != nil {
+		return err
+	}
+	e.client = client
+
+	e.cardinality = newCardinalityLimiter(e.config.Cardinality, e.mapper, e.logger, telemetry)
+	return nil
 }
 
-func (e *serviceNowProducer) logDataPusher(_ context.Context, md plog.Logs) error {
+func (e *serviceNowProducer) convertLogs(_ context.Context, md plog.Logs) (exporterhelper.Request, error) {
 	snLogs := make([]ServiceNowLog, 0)
 	snEvents := make([]ServiceNowEvent, 0)
+	alerts := make(alertChanges)
 
 	useLogs := e.config.PushLogsURL != ""
 
@@ -72,19 +141,25 @@ func (e *serviceNowProducer) logDataPusher(_ context.Context, md plog.Logs) erro
 
 			for k := 0; k < sl.LogRecords().Len(); k++ {
 				log := sl.LogRecords().At(k)
+				severity := e.severities.severity(log.SeverityNumber(), log.SeverityText())
 				if useLogs {
 					newLog := ServiceNowLog{
 						Body:         log.Body().AsString(),
-						ResourcePath: buildPath("", log.Attributes()),
-						Ci2LogID:     ci2metricAttrs(resourceAttrs),
+						ResourcePath: e.mapper.formatResourcePath(e.mapper.buildPath("", log.Attributes()), resourceAttrs, log.Attributes()),
+						Ci2LogID:     e.mapper.ci2metricAttrs(resourceAttrs),
 						Timestamp:    formatTimestamp(log.Timestamp()),
-						Severity:     log.SeverityText(),
-						Node:         formatNode(ci2metricAttrs(resourceAttrs)),
+						Severity:     severity,
+						Node:         e.mapper.formatNode(e.mapper.ci2metricAttrs(resourceAttrs)),
 						Source:       midSource,
 					}
+					// set by the cibinding processor
+					if ciSysID := newLog.Ci2LogID[ciSysIDAttribute]; ciSysID != "" {
+						newLog.CiSysId = ciSysID
+						newLog.Ci2LogID = nil
+					}
 					snLogs = append(snLogs, newLog)
 				} else {
-					additionalInfo, err := formatAdditionalInfo(ci2metricAttrs(log.Attributes()), ci2metricAttrs(resourceAttrs))
+					additionalInfo, err := e.mapper.formatAdditionalInfo(e.mapper.ci2metricAttrs(log.Attributes()), e.mapper.ci2metricAttrs(resourceAttrs))
 					if err != nil {
 						e.logger.Error("Failed to format additional info", zap.Error(err))
 						continue
@@ -93,43 +168,41 @@ func (e *serviceNowProducer) logDataPusher(_ context.Context, md plog.Logs) erro
 					newEvent := ServiceNowEvent{
 						Type:           scope,
 						Description:    log.Body().AsString(),
-						Resource:       buildPath("", log.Attributes()),
-						Severity:       "5", // TODO: figure out this mapping
+						Resource:       e.mapper.formatResource(e.mapper.buildPath("", log.Attributes()), resourceAttrs, log.Attributes()),
+						Severity:       severity,
 						Timestamp:      formatEventTimestamp(log.Timestamp()),
-						Node:           formatNode(ci2metricAttrs(resourceAttrs)),
+						Node:           e.mapper.formatNode(e.mapper.ci2metricAttrs(resourceAttrs)),
 						Source:         midSource,
 						AdditionalInfo: additionalInfo,
 					}
+					if e.config.KubernetesEvents.Enabled {
+						if ev, ok := parseK8sEvent(log, resourceAttrs); ok {
+							if ev.deleted {
+								e.logger.Debug("Skipping the deletion of a Kubernetes event",
+									zap.String("reason", ev.reason), zap.String("kind", ev.kind), zap.String("name", ev.name))
+								continue
+							}
+							e.k8sEvents.apply(&newEvent, ev, resourceAttrs, log.Attributes())
+						}
+					}
+					e.events.set(&newEvent, "", resourceAttrs, log.Attributes())
+					if e.config.Events.SendClearEvents {
+						e.events.track(&newEvent, alerts)
+					}
 					snEvents = append(snEvents, newEvent)
 				}
 			}
 		}
 	}
 
-	if useLogs {
-		e.logger.Info("Sending logs to MID Server...", zap.Any("logs", snLogs))
-		err := e.client.sendLogs(snLogs)
-		if err != nil {
-			e.logger.Error("Failed to send logs to MID Server", zap.Int("logCount", len(snLogs)), zap.Error(err))
-			return err
-		}
-
-		return nil
-	}
-
-	e.logger.Info("Sending events to instance...", zap.Int("eventCount", len(snEvents)))
-	err := e.client.sendEvents(snEvents)
-	if err != nil {
-		e.logger.Error("Failed to send events to MID Server", zap.Int("logCount", len(snEvents)), zap.Error(err))
-		return err
-	}
-
-	return nil
+	return &serviceNowRequest{producer: e, Events: snEvents, Logs: snLogs, alerts: alerts}, nil
 }
 
 // based on: https://github.com/open-telemetry/opentelemetry-collector-contrib/blob/main/exporter/carbonexporter/metricdata_to_plaintext.go#L82
-func (e *serviceNowProducer) metricsDataPusher(_ context.Context, md pmetric.Metrics) error {
+func (e *serviceNowProducer) convertMetrics(ctx context.Context, md pmetric.Metrics) (exporterhelper.Request, error) {
 	snMetrics := make([]ServiceNowMetric, 0)
+	snEvents := make([]ServiceNowEvent, 0)
+	breaches := make(breachChanges)
 
 	for i := 0; i < md.ResourceMetrics().Len(); i++ {
 		rm := md.ResourceMetrics().At(i)
@@ -144,32 +217,92 @@ func (e *serviceNowProducer) metricsDataPusher(_ context.Context, md pmetric.Met
 					// TODO: log error info
 					continue
 				}
+				snEvents = append(snEvents, e.rules.evaluate(metric, resourceAttrs, breaches)...)
+				if !e.filter.keepMetric(metric.Name(), resourceAttrs) {
+					continue
+				}
+				metric = e.filter.filterDataPoints(metric, resourceAttrs)
+				name := e.filter.rename(metric.Name())
+				metric = e.cumulative.convertMetric(name, resourceAttrs, metric)
+				metric = e.cardinality.limit(ctx, name, metric)
+
 				switch metric.Type() {
 				case pmetric.MetricTypeGauge:
-					snMetrics = append(snMetrics, e.writeNumberDataPoints(metric.Name(), scope, resourceAttrs, metric.Gauge().DataPoints())...)
+					snMetrics = append(snMetrics, e.writeNumberDataPoints(name, scope, resourceAttrs, metric.Gauge().DataPoints())...)
 				case pmetric.MetricTypeSum:
-					snMetrics = append(snMetrics, e.writeNumberDataPoints(metric.Name(), scope, resourceAttrs, metric.Sum().DataPoints())...)
+					snMetrics = append(snMetrics, e.writeNumberDataPoints(name, scope, resourceAttrs, metric.Sum().DataPoints())...)
 				case pmetric.MetricTypeHistogram:
-					snMetrics = append(snMetrics, e.formatHistogramDataPoints(metric.Name(), scope, resourceAttrs, metric.Histogram().DataPoints())...)
+					snMetrics = append(snMetrics, e.formatHistogramDataPoints(name, scope, resourceAttrs, metric.Histogram().DataPoints())...)
+				case pmetric.MetricTypeExponentialHistogram:
+					snMetrics = append(snMetrics, e.formatExponentialHistogramDataPoints(name, scope, resourceAttrs, metric.ExponentialHistogram().DataPoints())...)
 				case pmetric.MetricTypeSummary:
-					snMetrics = append(snMetrics, e.formatSummaryDataPoints(metric.Name(), scope, resourceAttrs, metric.Summary().DataPoints())...)
+					snMetrics = append(snMetrics, e.formatSummaryDataPoints(name, scope, resourceAttrs, metric.Summary().DataPoints())...)
 				}
 			}
 		}
 	}
 
-	e.logger.Info("Sending metrics to MID Server...", zap.Any("metrics", len(snMetrics)))
-	err := e.client.sendMetrics(snMetrics)
+	return &serviceNowRequest{producer: e, Events: snEvents, Metrics: snMetrics, breaches: breaches}, nil
+}
 
-	if err != nil {
-		e.logger.Error("Failed to send metric to MID Server", zap.Int("metricCount", len(snMetrics)), zap.Error(err))
-		return err
+func (e *serviceNowProducer) convertTraces(_ context.Context, td ptrace.Traces) (exporterhelper.Request, error) {
+	snEvents := make([]ServiceNowEvent, 0)
+	alerts := make(alertChanges)
+	// RED stats are aggregated per service and operation across all the resources and scopes
+	stats := newREDStats()
+
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		resourceAttrs := rs.Resource().Attributes()
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+				if e.config.Traces.SendREDMetrics {
+					stats.add(span, resourceAttrs)
+				}
+				if span.Status().Code() != ptrace.StatusCodeError {
+					// A span without an error may resolve the alert opened by a previous one.
+					if !e.config.Events.SendClearEvents || !e.events.hasOpenAlerts(alerts) {
+						continue
+					}
+					// The full event is only built for the spans resolving an open alert.
+					newEvent := e.newSpanEvent(span, resourceAttrs, severityInfo, "Span "+span.Name()+" ended without an error")
+					if !e.events.isOpen(alertKey(&newEvent), alerts) {
+						continue
+					}
+					additionalInfo, err := e.formatSpanAdditionalInfo(span, resourceAttrs)
+					if err != nil {
+						e.logger.Error("Failed to format additional info", zap.Error(err))
+						continue
+					}
+					newEvent.AdditionalInfo = additionalInfo
+					e.events.track(&newEvent, alerts)
+					snEvents = append(snEvents, newEvent)
+					continue
+				}
+
+				newEvent, err := e.createSpanEvent(span, resourceAttrs, spanErrorSeverity, formatSpanErrorDescription(span))
+				if err != nil {
+					e.logger.Error("Failed to format additional info", zap.Error(err))
+					continue
+				}
+				if e.config.Events.SendClearEvents {
+					e.events.track(&newEvent, alerts)
+				}
+				snEvents = append(snEvents, newEvent)
+			}
+		}
 	}
-	return nil
+
+	return &serviceNowRequest{producer: e, Events: snEvents, Metrics: e.formatREDMetrics(stats), alerts: alerts}, nil
 }
 
 func (e *serviceNowProducer) Close(context.Context) error {
-	e.client.Close()
+	if e.client != nil {
+		e.client.Close()
+	}
 	return nil
 }
 
@@ -177,52 +310,42 @@ func (e *serviceNowProducer) writeNumberDataPoints(metricName string, scope stri
 	snm := make([]ServiceNowMetric, 0)
 	for i := 0; i < dps.Len(); i++ {
 		dp := dps.At(i)
-		var val float64
-		switch dp.ValueType() {
-		case pmetric.NumberDataPointValueTypeEmpty:
-			continue // skip this data point - otherwise an empty string will be used as the value and the backend will use the timestamp as the metric value
-		case pmetric.NumberDataPointValueTypeInt:
-			val = float64(dp.IntValue())
-		case pmetric.NumberDataPointValueTypeDouble:
-			val = float64(dp.DoubleValue())
+		val, ok := numberValue(dp)
+		if !ok {
+			continue
 		}
-		snm = append(snm, e.createMetric(
-			metricName,
-			scope,
-			ci2metricAttrs(rAttrs),
-			buildPath(metricName, dp.Attributes()),
-			val,
-			formatTimestamp(dp.Timestamp())))
+		snm = append(snm, e.createNumberMetric(metricName, scope, rAttrs, dp, val))
 	}
 	return snm
 }
 
-// Converts resource attributes to a map of string key/value pairs
-// for use in ci2metric_id in the push metric API
-func ci2metricAttrs(rAttrs pcommon.Map) map[string]string {
-	attrs := make(map[string]string)
-	rAttrs.Range(func(k string, v pcommon.Value) bool {
-		if v.Type() == pcommon.ValueTypeStr {
-			attrs[k] = v.AsString()
-		}
-		if v.Type() == pcommon.ValueTypeMap {
-			v.Map().Range(func(k2 string, v2 pcommon.Value) bool {
-				if v2.Type() == pcommon.ValueTypeStr {
-					attrs[k+"."+k2] = v2.AsString()
-				}
-				return true
-			})
-		}
-		return true
-	})
-	return attrs
+func (e *serviceNowProducer) createNumberMetric(metricName string, scope string, rAttrs pcommon.Map, dp pmetric.NumberDataPoint, val float64) ServiceNowMetric {
+	return e.createMetric(
+		metricName,
+		scope,
+		e.mapper.ci2metricAttrs(rAttrs),
+		e.mapper.formatResourcePath(e.mapper.buildPath(metricName, dp.Attributes()), rAttrs, dp.Attributes()),
+		val,
+		formatTimestamp(dp.Timestamp()))
+}
+
+// numberValue returns the value of a data point, false when it is empty.
+func numberValue(dp pmetric.NumberDataPoint) (float64, bool) {
+	switch dp.ValueType() {
+	case pmetric.NumberDataPointValueTypeInt:
+		return float64(dp.IntValue()), true
+	case pmetric.NumberDataPointValueTypeDouble:
+		return dp.DoubleValue(), true
+	}
+	// skip this data point - otherwise an empty string will be used as the value and the backend will use the timestamp as the metric value
+	return 0, false
 }
 
 // formatHistogramDataPoints transforms a slice of histogram data points into a series
-// of Carbon metrics and injects them into the string builder.
+// of Carbon metrics, per the configured histograms strategy.
 //
 // Carbon doesn't have direct support to distribution metrics they will be
-// translated into a series of Carbon metrics:
+// translated into a series of Carbon metrics, by default:
 //
 // 1. The total count will be represented by a metric named "<metricName>.count".
 //
@@ -239,32 +362,24 @@ func (e *serviceNowProducer) formatHistogramDataPoints(
 	dps pmetric.HistogramDataPointSlice,
 ) []ServiceNowMetric {
 	snm := make([]ServiceNowMetric, 0)
-
 	for i := 0; i < dps.Len(); i++ {
-		dp := dps.At(i)
-
-		e.formatCountAndSum(metricName, scope, rAttrs, dp.Attributes(), dp.Count(), dp.Sum(), dp.Timestamp())
-		if dp.ExplicitBounds().Len() == 0 {
-			continue
-		}
-
-		bounds := dp.ExplicitBounds().AsRaw()
-		carbonBounds := make([]string, len(bounds)+1)
-		for i := 0; i < len(bounds); i++ {
-			carbonBounds[i] = formatFloatForLabel(bounds[i])
-		}
-		carbonBounds[len(carbonBounds)-1] = infinityCarbonValue
+		snm = append(snm, e.formatHistogramPoint(metricName, scope, rAttrs, newExplicitHistogramPoint(dps.At(i)))...)
+	}
+	return snm
+}
 
-		bucketPath := buildPath(metricName+distributionBucketSuffix, dp.Attributes())
-		for j := 0; j < dp.BucketCounts().Len(); j++ {
-			snm = append(snm, e.createMetric(
-				metricName+distributionBucketSuffix,
-				scope,
-				ci2metricAttrs(rAttrs),
-				bucketPath+distributionUpperBoundTagBeforeValue+carbonBounds[j],
-				float64(dp.BucketCounts().At(j)),
-				formatTimestamp(dp.Timestamp())))
-		}
+// formatExponentialHistogramDataPoints transforms a slice of exponential histogram data points
+// into a series of Carbon metrics like formatHistogramDataPoints, the upper_bound of each bucket
+// being derived from its index and the scale.
+func (e *serviceNowProducer) formatExponentialHistogramDataPoints(
+	metricName string,
+	scope string,
+	rAttrs pcommon.Map,
+	dps pmetric.ExponentialHistogramDataPointSlice,
+) []ServiceNowMetric {
+	snm := make([]ServiceNowMetric, 0)
+	for i := 0; i < dps.Len(); i++ {
+		snm = append(snm, e.formatHistogramPoint(metricName, scope, rAttrs, newExponentialHistogramPoint(dps.At(i)))...)
 	}
 	return snm
 }
@@ -291,18 +406,18 @@ func (e *serviceNowProducer) formatSummaryDataPoints(
 	for i := 0; i < dps.Len(); i++ {
 		dp := dps.At(i)
 
-		e.formatCountAndSum(metricName, scope, rAttrs, dp.Attributes(), dp.Count(), dp.Sum(), dp.Timestamp())
+		snm = append(snm, e.formatCountAndSum(metricName, scope, rAttrs, dp.Attributes(), dp.Count(), dp.Sum(), dp.Timestamp())...)
 
 		if dp.QuantileValues().Len() == 0 {
 			continue
 		}
 
-		quantilePath := buildPath(metricName+summaryQuantileSuffix, dp.Attributes())
+		quantilePath := e.mapper.formatResourcePath(e.mapper.buildPath(metricName+summaryQuantileSuffix, dp.Attributes()), rAttrs, dp.Attributes())
 		for j := 0; j < dp.QuantileValues().Len(); j++ {
 			snm = append(snm, e.createMetric(
 				metricName+summaryQuantileSuffix,
 				scope,
-				ci2metricAttrs(rAttrs),
+				e.mapper.ci2metricAttrs(rAttrs),
 				quantilePath+summaryQuantileTagBeforeValue+formatFloatForLabel(dp.QuantileValues().At(j).Quantile()*100),
 				dp.QuantileValues().At(j).Value(),
 				formatTimestamp(dp.Timestamp())))
@@ -317,7 +432,7 @@ func (e *serviceNowProducer) formatSummaryDataPoints(
 //
 // 1. The total count will be represented by a metric named "<metricName>.count".
 //
-// 2. The total sum will be represented by a metruc with the original "<metricName>".
+// 2. The total sum will be represented by a metric with the original "<metricName>".
 func (e *serviceNowProducer) formatCountAndSum(
 	metricName string,
 	scope string,
@@ -330,77 +445,161 @@ func (e *serviceNowProducer) formatCountAndSum(
 	snm := make([]ServiceNowMetric, 0, 2)
 	// Write count and sum metrics.
 	snm = append(snm, e.createMetric(
-		metricName,
+		metricName+countSuffix,
 		scope,
-		ci2metricAttrs(rAttrs),
-		buildPath(metricName+countSuffix, attributes),
+		e.mapper.ci2metricAttrs(rAttrs),
+		e.mapper.formatResourcePath(e.mapper.buildPath(metricName+countSuffix, attributes), rAttrs, attributes),
 		float64(count),
 		formatTimestamp(timestamp)))
 
 	snm = append(snm, e.createMetric(
 		metricName,
 		scope,
-		ci2metricAttrs(rAttrs),
-		buildPath(metricName, attributes),
+		e.mapper.ci2metricAttrs(rAttrs),
+		e.mapper.formatResourcePath(e.mapper.buildPath(metricName, attributes), rAttrs, attributes),
 		sum,
 		formatTimestamp(timestamp)))
 	return snm
 }
 
-// buildPath is used to build the <metric_path> per description above.
-func buildPath(name string, attributes pcommon.Map) string {
-	if attributes.Len() == 0 {
-		return name
+// operationStats accumulates the RED values of all the spans of a service sharing an operation name.
+type operationStats struct {
+	service    string
+	operation  string
+	requests   uint64
+	errors     uint64
+	durationMs float64
+	timestamp  pcommon.Timestamp
+	// resourceAttrs are the attributes of the first resource the operation was seen in
+	resourceAttrs pcommon.Map
+}
+
+// redStats keeps the per service and operation stats in the order operations were first seen,
+// and the time window covered by the spans of the batch.
+type redStats struct {
+	index map[[2]string]int
+	ops   []*operationStats
+	start pcommon.Timestamp
+	end   pcommon.Timestamp
+}
+
+func newREDStats() *redStats {
+	return &redStats{index: make(map[[2]string]int)}
+}
+
+func (s *redStats) add(span ptrace.Span, resourceAttrs pcommon.Map) {
+	service := mapString(resourceAttrs, "service.name")
+	key := [2]string{service, span.Name()}
+	idx, ok := s.index[key]
+	if !ok {
+		idx = len(s.ops)
+		s.index[key] = idx
+		s.ops = append(s.ops, &operationStats{service: service, operation: span.Name(), resourceAttrs: resourceAttrs})
 	}
 
-	buf := new(bytes.Buffer)
+	if s.start == 0 || span.StartTimestamp() < s.start {
+		s.start = span.StartTimestamp()
+	}
+	if span.EndTimestamp() > s.end {
+		s.end = span.EndTimestamp()
+	}
 
-	buf.WriteString(name)
-	attributes.Range(func(k string, v pcommon.Value) bool {
-		if v.Type() != pcommon.ValueTypeStr {
-			return true
-		}
-		value := v.AsString()
-		if value == "" {
-			value = tagValueEmptyPlaceholder
-		}
-		buf.WriteString(tagPrefix)
-		buf.WriteString(sanitizeTagKey(k))
-		buf.WriteString(tagKeyValueSeparator)
-		buf.WriteString(value)
-		return true
-	})
+	op := s.ops[idx]
+	op.requests++
+	if span.Status().Code() == ptrace.StatusCodeError {
+		op.errors++
+	}
+	if span.EndTimestamp() > span.StartTimestamp() {
+		op.durationMs += float64(span.EndTimestamp()-span.StartTimestamp()) / 1e6
+	}
+	if span.EndTimestamp() > op.timestamp {
+		op.timestamp = span.EndTimestamp()
+	}
+}
 
-	return buf.String()
+// window returns the seconds between the first span start and the last span end of the batch,
+// at least a second so a batch of short spans doesn't report a burst.
+func (s *redStats) window() float64 {
+	if s.end <= s.start {
+		return 1
+	}
+	return max(s.end.AsTime().Sub(s.start.AsTime()).Seconds(), 1)
 }
 
-func formatAdditionalInfo(attrs map[string]string, resourceAttrs map[string]string) (map[string]string, error) {
-	// merge attrs + resource attrs
-	newAttrs := make(map[string]string)
-	for k, v := range resourceAttrs {
-		if v == "" {
-			continue
+// formatREDMetrics transforms the stats of each service operation into three metrics:
+//
+// 1. The number of spans per second over the window of the batch will be represented by a metric
+// named "span.requests".
+//
+// 2. The number of spans with an error status per second will be represented by a metric named "span.errors".
+//
+// 3. The average span duration, in milliseconds, will be represented by a metric named "span.duration".
+//
+// All of them include a "span.name" tag with the operation name, and their resource_path goes
+// through the mapping like the one of any other metric.
+func (e *serviceNowProducer) formatREDMetrics(stats *redStats) []ServiceNowMetric {
+	snm := make([]ServiceNowMetric, 0, 3*len(stats.ops))
+	window := stats.window()
+	for _, op := range stats.ops {
+		attrs := pcommon.NewMap()
+		attrs.PutStr(spanNameTagKey, op.operation)
+		timestamp := formatTimestamp(op.timestamp)
+
+		for _, m := range []struct {
+			metricType string
+			value      float64
+		}{
+			{spanRequestsMetricType, float64(op.requests) / window},
+			{spanErrorsMetricType, float64(op.errors) / window},
+			{spanDurationMetricType, op.durationMs / float64(op.requests)},
+		} {
+			path := e.mapper.formatResourcePath(e.mapper.buildPath(m.metricType, attrs), op.resourceAttrs, attrs)
+			snm = append(snm, e.createMetric(m.metricType, "", e.mapper.ci2metricAttrs(op.resourceAttrs), path, m.value, timestamp))
 		}
-		// replace . with _ in the key
-		k = strings.ReplaceAll(k, ".", "_")
-		newAttrs[k] = v
 	}
+	return snm
+}
 
-	for k, v := range attrs {
-		if v == "" {
-			continue
-		}
-		// replace . with _ in the key
-		k = strings.ReplaceAll(k, ".", "_")
-		newAttrs[k] = v
+func (e *serviceNowProducer) createSpanEvent(span ptrace.Span, resourceAttrs pcommon.Map, severity string, description string) (ServiceNowEvent, error) {
+	additionalInfo, err := e.formatSpanAdditionalInfo(span, resourceAttrs)
+	if err != nil {
+		return ServiceNowEvent{}, err
+	}
+	event := e.newSpanEvent(span, resourceAttrs, severity, description)
+	event.AdditionalInfo = additionalInfo
+	return event, nil
+}
+
+// newSpanEvent returns the event of a span without its additional_info, enough to find its alert.
+func (e *serviceNowProducer) newSpanEvent(span ptrace.Span, resourceAttrs pcommon.Map, severity string, description string) ServiceNowEvent {
+	event := ServiceNowEvent{
+		Type:        span.Name(),
+		Description: description,
+		Resource:    e.mapper.formatResource(e.mapper.ci2metricAttrs(resourceAttrs)["service.name"], resourceAttrs, span.Attributes()),
+		Severity:    severity,
+		Timestamp:   formatEventTimestamp(span.EndTimestamp()),
+		Node:        e.mapper.formatNode(e.mapper.ci2metricAttrs(resourceAttrs)),
+		Source:      midSource,
 	}
+	e.events.set(&event, span.Name(), resourceAttrs, span.Attributes())
+	return event
+}
 
-	return newAttrs, nil
+func (e *serviceNowProducer) formatSpanAdditionalInfo(span ptrace.Span, resourceAttrs pcommon.Map) (map[string]string, error) {
+	additionalInfo, err := e.mapper.formatAdditionalInfo(e.mapper.ci2metricAttrs(span.Attributes()), e.mapper.ci2metricAttrs(resourceAttrs))
+	if err != nil {
+		return nil, err
+	}
+	additionalInfo["trace_id"] = span.TraceID().String()
+	additionalInfo["span_id"] = span.SpanID().String()
+	return additionalInfo, nil
 }
 
-func formatNode(resourceAttrs map[string]string) string {
-	// TODO: make this mapping support more than host.name
-	return resourceAttrs["host.name"]
+func formatSpanErrorDescription(span ptrace.Span) string {
+	if msg := span.Status().Message(); msg != "" {
+		return msg
+	}
+	return "Span " + span.Name() + " ended with an error status"
 }
 
 func (e *serviceNowProducer) createMetric(name string, scope string, resourceAttrs map[string]string, path string, value float64, timestamp uint64) ServiceNowMetric {
@@ -417,14 +616,14 @@ func (e *serviceNowProducer) createMetric(name string, scope string, resourceAtt
 		Ci2MetricID:  resourceAttrs,
 	}
 
-	// set by a processor (does not exist yet)
-	ciClass := resourceAttrs["servicenow.ci.sys_id"]
-	if ciClass != "" {
-		snm.CiSysId = ciClass
+	// set by the cibinding processor
+	ciSysID := resourceAttrs[ciSysIDAttribute]
+	if ciSysID != "" {
+		snm.CiSysId = ciSysID
 		snm.Ci2MetricID = nil
 	}
 
-	snm.Node = formatNode(resourceAttrs)
+	snm.Node = e.mapper.formatNode(resourceAttrs)
 
 	return snm
 }
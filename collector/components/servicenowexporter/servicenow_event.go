@@ -1,5 +1,7 @@
 package servicenowexporter
 
+import "encoding/json"
+
 // https://docs.servicenow.com/bundle/vancouver-it-operations-management/page/product/event-management/task/send-events-via-web-service.html
 type ServiceNowEvent struct {
 	// The resource on the node impacted
@@ -13,4 +15,16 @@ type ServiceNowEvent struct {
 	// k8s.cluster.name:test-cluster,k8s.cluster.uid=12345
 	AdditionalInfo map[string]string `json:"additional_info,omitempty"` // actually a json string
 	Source         string            `json:"source"`
+	// MessageKey identifies the alert updated by the event, see EventsConfig
+	MessageKey string `json:"message_key,omitempty"`
+	// EventClass is the class of the source sending the event
+	EventClass string `json:"event_class,omitempty"`
+	// MetricName is the metric, or operation, the event is about
+	MetricName string `json:"metric_name,omitempty"`
+}
+
+// ServiceNowEventBatch sends several events in a single request to the inbound_event API.
+// Records holds already serialized ServiceNowEvents so the payload size can be tracked while batching.
+type ServiceNowEventBatch struct {
+	Records []json.RawMessage `json:"records"`
 }
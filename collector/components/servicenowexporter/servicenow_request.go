@@ -0,0 +1,212 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/exporter/exporterqueue"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+// serviceNowRequest holds the ServiceNow payloads converted from a batch of telemetry. Converting
+// before queueing means retries send the same payloads, and the stateful conversions (cumulative
+// sums, cardinality) only see each data point once. It is serialized to JSON when the sending
+// queue uses a storage extension.
+type serviceNowRequest struct {
+	producer *serviceNowProducer
+
+	Events  []ServiceNowEvent  `json:"events,omitempty"`
+	Logs    []ServiceNowLog    `json:"logs,omitempty"`
+	Metrics []ServiceNowMetric `json:"metrics,omitempty"`
+
+	// alerts are the alerts opened or resolved by the events, committed once they are sent. They
+	// are not stored by the persistent queue, the open alerts being forgotten on restart anyway.
+	alerts alertChanges
+	// breaches are the series of the threshold rules events, committed the same way.
+	breaches breachChanges
+}
+
+// partialExportError is returned when only part of a request was sent, remaining holds the rest.
+type partialExportError struct {
+	err       error
+	remaining *serviceNowRequest
+}
+
+func (e *partialExportError) Error() string {
+	return e.err.Error()
+}
+
+func (e *partialExportError) Unwrap() error {
+	return e.err
+}
+
+// Export sends the events, logs and metrics in that order.
+func (r *serviceNowRequest) Export(ctx context.Context) error {
+	err := r.export(ctx)
+	var partialErr *partialExportError
+	if err != nil && !errors.As(err, &partialErr) {
+		// The whole request is left to send, OnError then returns it even after earlier partial retries.
+		err = &partialExportError{err: err, remaining: r}
+	}
+	if consumererror.IsPermanent(err) {
+		// The part of the request left to send is dropped without retrying.
+		r.OnError(err).(*serviceNowRequest).recordDropped(ctx)
+	}
+	return err
+}
+
+func (r *serviceNowRequest) export(ctx context.Context) error {
+	e := r.producer
+
+	// rejected is the error of the event batches dropped by the client, the logs and metrics
+	// still being sent.
+	var rejected error
+	if len(r.Events) > 0 {
+		e.logger.Debug("Sending events to instance...", zap.Int("eventCount", len(r.Events)))
+		sent, err := e.client.sendEvents(ctx, r.Events)
+		switch {
+		case err == nil:
+			e.events.commit(r.alerts)
+			e.rules.commit(r.breaches)
+		case sent == len(r.Events):
+			rejected = err
+			// The rejected events would be rejected again, their alerts are committed as well.
+			e.events.commit(r.alerts)
+			e.rules.commit(r.breaches)
+		default:
+			e.logger.Error("Failed to send events to instance", zap.Int("eventCount", len(r.Events)), zap.Int("sentCount", sent), zap.Error(err))
+			if sent == 0 {
+				return err
+			}
+			// Only retry the events that were not accepted.
+			remaining := *r
+			remaining.Events = r.Events[sent:]
+			return &partialExportError{err: err, remaining: &remaining}
+		}
+	}
+
+	if len(r.Logs) > 0 {
+		e.logger.Debug("Sending logs to MID Server...", zap.Int("logCount", len(r.Logs)))
+		if err := e.client.sendLogs(ctx, r.Logs); err != nil {
+			e.logger.Error("Failed to send logs to MID Server", zap.Int("logCount", len(r.Logs)), zap.Error(err))
+			return r.withRemaining(err, false)
+		}
+	}
+
+	if len(r.Metrics) > 0 {
+		e.logger.Debug("Sending metrics to MID Server...", zap.Int("metricCount", len(r.Metrics)))
+		if err := e.client.sendMetrics(ctx, r.Metrics); err != nil {
+			e.logger.Error("Failed to send metrics to MID Server", zap.Int("metricCount", len(r.Metrics)), zap.Error(err))
+			return r.withRemaining(err, true)
+		}
+	}
+
+	if rejected != nil {
+		// Nothing is left to send, the rejected events were already counted as dropped.
+		return &partialExportError{err: rejected, remaining: &serviceNowRequest{producer: e}}
+	}
+	return nil
+}
+
+// withRemaining wraps err with the request left to send once the events, and the logs if logsSent,
+// were sent.
+func (r *serviceNowRequest) withRemaining(err error, logsSent bool) error {
+	if len(r.Events) == 0 && (!logsSent || len(r.Logs) == 0) {
+		return err
+	}
+	remaining := *r
+	remaining.Events = nil
+	remaining.alerts = nil
+	remaining.breaches = nil
+	if logsSent {
+		remaining.Logs = nil
+	}
+	return &partialExportError{err: err, remaining: &remaining}
+}
+
+func (r *serviceNowRequest) recordDropped(ctx context.Context) {
+	dropped := r.producer.telemetry.ServicenowExporterItemsDropped
+	for endpoint, count := range map[string]int{
+		endpointEvents:  len(r.Events),
+		endpointLogs:    len(r.Logs),
+		endpointMetrics: len(r.Metrics),
+	} {
+		if count > 0 {
+			dropped.Add(ctx, int64(count), metric.WithAttributes(attribute.String("endpoint", endpoint)))
+		}
+	}
+}
+
+// OnError returns the part of the request left to send.
+func (r *serviceNowRequest) OnError(err error) exporterhelper.Request {
+	var partialErr *partialExportError
+	if errors.As(err, &partialErr) {
+		return partialErr.remaining
+	}
+	return r
+}
+
+func (r *serviceNowRequest) ItemsCount() int {
+	return len(r.Events) + len(r.Logs) + len(r.Metrics)
+}
+
+// queueFactory returns a persistent queue storing the requests as JSON when sending_queue.storage is set,
+// a memory queue otherwise.
+func (e *serviceNowProducer) queueFactory() exporterqueue.Factory[exporterhelper.Request] {
+	factory := exporterqueue.NewPersistentQueueFactory[exporterhelper.Request](e.config.QueueSettings.StorageID, exporterqueue.PersistentQueueSettings[exporterhelper.Request]{
+		Marshaler: func(req exporterhelper.Request) ([]byte, error) {
+			return json.Marshal(req)
+		},
+		Unmarshaler: func(data []byte) (exporterhelper.Request, error) {
+			req := &serviceNowRequest{producer: e}
+			if err := json.Unmarshal(data, req); err != nil {
+				return nil, err
+			}
+			return req, nil
+		},
+	})
+	return func(ctx context.Context, set exporterqueue.Settings, cfg exporterqueue.Config) exporterqueue.Queue[exporterhelper.Request] {
+		return &droppedItemsQueue{Queue: factory(ctx, set, cfg)}
+	}
+}
+
+// droppedItemsQueue records the items dropped by the sending queue: the requests offered while it
+// is full, and the part of a request still failing once the retries are exhausted. Without the
+// sending queue, the error is returned to the pipeline instead. With a persistent queue, a request
+// interrupted by a shutdown is counted as well, although it is sent again after the restart.
+type droppedItemsQueue struct {
+	exporterqueue.Queue[exporterhelper.Request]
+}
+
+func (q *droppedItemsQueue) Offer(ctx context.Context, req exporterhelper.Request) error {
+	err := q.Queue.Offer(ctx, req)
+	if errors.Is(err, exporterqueue.ErrQueueIsFull) {
+		req.(*serviceNowRequest).recordDropped(ctx)
+	}
+	return err
+}
+
+func (q *droppedItemsQueue) Consume(consumeFunc func(context.Context, exporterhelper.Request) error) bool {
+	return q.Queue.Consume(func(ctx context.Context, req exporterhelper.Request) error {
+		err := consumeFunc(ctx, req)
+		// Export already recorded the items dropped after a permanent error.
+		if err != nil && !consumererror.IsPermanent(err) {
+			req.(*serviceNowRequest).OnError(err).(*serviceNowRequest).recordDropped(ctx)
+		}
+		return err
+	})
+}
+
+// queueConfig returns the sending_queue settings for the queue created by queueFactory.
+func (e *serviceNowProducer) queueConfig() exporterqueue.Config {
+	return exporterqueue.Config{
+		Enabled:      e.config.QueueSettings.Enabled,
+		NumConsumers: e.config.QueueSettings.NumConsumers,
+		QueueSize:    e.config.QueueSettings.QueueSize,
+	}
+}
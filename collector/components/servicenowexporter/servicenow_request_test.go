@@ -0,0 +1,170 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/extension/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/config/configretry"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/exporter/exporterqueue"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/extension/extensiontest"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestExportRetriesOnlyUnsentPayloads(t *testing.T) {
+	mid := newMidServerMock(t)
+	unavailable := newMidServerMock(t)
+	unavailable.down.Store(true)
+	cfg := mid.config()
+	cfg.PushMetricsURL = unavailable.server.URL + "/metrics"
+	producer := newTestProducer(t, cfg)
+
+	req := &serviceNowRequest{
+		producer: producer,
+		Events:   []ServiceNowEvent{{Description: "failed"}},
+		Metrics:  []ServiceNowMetric{{MetricType: "span.requests", Value: 1}},
+	}
+	err := req.Export(context.Background())
+	require.Error(t, err)
+
+	var partialErr *partialExportError
+	require.True(t, errors.As(err, &partialErr))
+	remaining := req.OnError(err).(*serviceNowRequest)
+	assert.Empty(t, remaining.Events)
+	assert.Len(t, remaining.Metrics, 1)
+	assert.Equal(t, 1, remaining.ItemsCount())
+	assert.Len(t, mid.received("/events"), 1)
+}
+
+// storageHost provides the storage extensions to the exporter.
+type storageHost struct {
+	component.Host
+	extensions map[component.ID]component.Component
+}
+
+func (h *storageHost) GetExtensions() map[component.ID]component.Component {
+	return h.extensions
+}
+
+// startWithFileStorage starts a file_storage extension using directory and the exporter, and returns
+// a function shutting both down.
+func startWithFileStorage(t *testing.T, directory string, storageID component.ID, exp component.Component) func() {
+	factory := filestorage.NewFactory()
+	storageCfg := factory.CreateDefaultConfig().(*filestorage.Config)
+	storageCfg.Directory = directory
+	ext, err := factory.CreateExtension(context.Background(), extensiontest.NewNopCreateSettings(), storageCfg)
+	require.NoError(t, err)
+
+	host := &storageHost{
+		Host:       componenttest.NewNopHost(),
+		extensions: map[component.ID]component.Component{storageID: ext},
+	}
+	require.NoError(t, ext.Start(context.Background(), host))
+	require.NoError(t, exp.Start(context.Background(), host))
+
+	return func() {
+		require.NoError(t, exp.Shutdown(context.Background()))
+		require.NoError(t, ext.Shutdown(context.Background()))
+	}
+}
+
+func TestPersistentQueueSurvivesRestart(t *testing.T) {
+	mid := newMidServerMock(t)
+	mid.down.Store(true)
+
+	storageID := component.MustNewID("file_storage")
+	cfg := mid.config()
+	cfg.QueueSettings.Enabled = true
+	cfg.QueueSettings.NumConsumers = 1
+	cfg.QueueSettings.StorageID = &storageID
+	cfg.BackOffConfig = configretry.BackOffConfig{
+		Enabled:         true,
+		InitialInterval: 10 * time.Millisecond,
+		MaxInterval:     10 * time.Millisecond,
+	}
+	directory := t.TempDir()
+	// The storage is keyed by the exporter ID, which must not change across restarts.
+	set := exportertest.NewNopCreateSettings()
+
+	md := pmetric.NewMetrics()
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("cpu")
+	metric.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(0.5)
+
+	// The MID Server is down, the payload stays in the queue until the collector stops.
+	exp, err := NewFactory().CreateMetricsExporter(context.Background(), set, cfg)
+	require.NoError(t, err)
+	shutdown := startWithFileStorage(t, directory, storageID, exp)
+	require.NoError(t, exp.ConsumeMetrics(context.Background(), md))
+	require.Eventually(t, func() bool { return mid.attempts.Load() > 1 }, 5*time.Second, 10*time.Millisecond)
+	shutdown()
+	assert.Empty(t, mid.received("/metrics"))
+
+	// After the restart, the payload is read from the storage and sent.
+	mid.down.Store(false)
+	exp, err = NewFactory().CreateMetricsExporter(context.Background(), set, cfg)
+	require.NoError(t, err)
+	shutdown = startWithFileStorage(t, directory, storageID, exp)
+	defer shutdown()
+	require.Eventually(t, func() bool { return len(mid.received("/metrics")) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	var metrics []ServiceNowMetric
+	require.NoError(t, json.Unmarshal(mid.received("/metrics")[0], &metrics))
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "cpu", metrics[0].MetricType)
+	assert.Equal(t, 0.5, metrics[0].Value)
+}
+
+func TestQueueRecordsDroppedItems(t *testing.T) {
+	settings, reader := newTestTelemetrySettings()
+	producer, err := newServiceNowProducer(settings, createDefaultConfig().(*Config))
+	require.NoError(t, err)
+	require.NoError(t, producer.start(context.Background(), componenttest.NewNopHost()))
+	defer producer.Close(context.Background())
+
+	q := producer.queueFactory()(context.Background(), exporterqueue.Settings{
+		DataType:         component.DataTypeMetrics,
+		ExporterSettings: exportertest.NewNopCreateSettings(),
+	}, exporterqueue.Config{Enabled: true, NumConsumers: 1, QueueSize: 1})
+	require.NoError(t, q.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() { require.NoError(t, q.Shutdown(context.Background())) }()
+
+	createRequest := func(metrics int) *serviceNowRequest {
+		return &serviceNowRequest{producer: producer, Metrics: make([]ServiceNowMetric, metrics)}
+	}
+	droppedMetrics := func() int64 {
+		return sumValue(t, collectMetric(t, reader, "servicenow_exporter_items_dropped"), attribute.String("endpoint", endpointMetrics))
+	}
+
+	// The queue is full.
+	require.NoError(t, q.Offer(context.Background(), createRequest(2)))
+	assert.ErrorIs(t, q.Offer(context.Background(), createRequest(3)), exporterqueue.ErrQueueIsFull)
+	assert.EqualValues(t, 3, droppedMetrics())
+
+	// The retries are exhausted, only the part of the request left is dropped.
+	assert.True(t, q.Consume(func(_ context.Context, req exporterhelper.Request) error {
+		remaining := *req.(*serviceNowRequest)
+		remaining.Metrics = remaining.Metrics[1:]
+		return fmt.Errorf("no more retries left: %w", &partialExportError{err: errors.New("unavailable"), remaining: &remaining})
+	}))
+	assert.EqualValues(t, 4, droppedMetrics())
+
+	// The items dropped after a permanent error are recorded by Export.
+	require.NoError(t, q.Offer(context.Background(), createRequest(2)))
+	assert.True(t, q.Consume(func(context.Context, exporterhelper.Request) error {
+		return consumererror.NewPermanent(errors.New("rejected"))
+	}))
+	assert.EqualValues(t, 4, droppedMetrics())
+}
@@ -0,0 +1,355 @@
+package servicenowexporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/lightstep/sn-collector/collector/servicenowexporter/internal/metadata"
+)
+
+// midServerMock records the payloads received on each endpoint. It answers 503 without recording
+// anything while down is set.
+type midServerMock struct {
+	mu       sync.Mutex
+	requests map[string][][]byte
+	server   *httptest.Server
+	down     atomic.Bool
+	attempts atomic.Int32
+}
+
+func newMidServerMock(t *testing.T) *midServerMock {
+	m := &midServerMock{requests: make(map[string][][]byte)}
+	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		m.attempts.Add(1)
+		if m.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		m.mu.Lock()
+		m.requests[r.URL.Path] = append(m.requests[r.URL.Path], body)
+		m.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *midServerMock) received(path string) [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[path]
+}
+
+func (m *midServerMock) config() *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushMetricsURL = m.server.URL + "/metrics"
+	cfg.PushEventsURL = m.server.URL + "/events"
+	cfg.PushLogsURL = ""
+	return cfg
+}
+
+func newTestProducer(t *testing.T, cfg *Config) *serviceNowProducer {
+	producer, err := newServiceNowProducer(componenttest.NewNopTelemetrySettings(), cfg)
+	require.NoError(t, err)
+	require.NoError(t, producer.start(context.Background(), componenttest.NewNopHost()))
+	t.Cleanup(func() { require.NoError(t, producer.Close(context.Background())) })
+	return producer
+}
+
+// newTestTelemetrySettings returns telemetry settings whose metrics are collected by the returned reader.
+func newTestTelemetrySettings() (component.TelemetrySettings, *sdkmetric.ManualReader) {
+	reader := sdkmetric.NewManualReader()
+	settings := componenttest.NewNopTelemetrySettings()
+	settings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	return settings, reader
+}
+
+func newTestTelemetryBuilder(t *testing.T, settings component.TelemetrySettings) *metadata.TelemetryBuilder {
+	telemetry, err := metadata.NewTelemetryBuilder(settings)
+	require.NoError(t, err)
+	return telemetry
+}
+
+// collectMetric returns the internal metric with the given name, failing the test when it was not recorded.
+func collectMetric(t *testing.T, reader *sdkmetric.ManualReader, name string) metricdata.Metrics {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	require.Failf(t, "metric not recorded", "metric %s", name)
+	return metricdata.Metrics{}
+}
+
+func newTestMidClient(t *testing.T, cfg *Config) *midClient {
+	settings := componenttest.NewNopTelemetrySettings()
+	client, err := newMidClient(context.Background(), componenttest.NewNopHost(), settings, newTestTelemetryBuilder(t, settings), cfg)
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	return client
+}
+
+// exportRequest exports a request returned by one of the producer converters.
+func exportRequest(req exporterhelper.Request, err error) error {
+	if err != nil {
+		return err
+	}
+	return req.Export(context.Background())
+}
+
+// decodeEventBatch decodes a {"records": [...]} payload sent to the events endpoint.
+func decodeEventBatch(t *testing.T, payload []byte) []ServiceNowEvent {
+	var batch struct {
+		Records []ServiceNowEvent `json:"records"`
+	}
+	require.NoError(t, json.Unmarshal(payload, &batch))
+	return batch.Records
+}
+
+func createTestTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "checkout")
+	rs.Resource().Attributes().PutStr("host.name", "host-1")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+
+	start := time.Unix(1700000000, 0)
+	ok := spans.AppendEmpty()
+	ok.SetName("GET /cart")
+	ok.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	ok.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(100 * time.Millisecond)))
+
+	failed := spans.AppendEmpty()
+	failed.SetName("GET /cart")
+	failed.SetTraceID(pcommon.TraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+	failed.SetSpanID(pcommon.SpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	failed.SetStartTimestamp(pcommon.NewTimestampFromTime(start))
+	failed.SetEndTimestamp(pcommon.NewTimestampFromTime(start.Add(300 * time.Millisecond)))
+	failed.Status().SetCode(ptrace.StatusCodeError)
+	failed.Status().SetMessage("connection refused")
+	failed.Attributes().PutStr("http.method", "GET")
+	return td
+}
+
+func TestConvertTracesErrorEvents(t *testing.T) {
+	mid := newMidServerMock(t)
+	producer := newTestProducer(t, mid.config())
+
+	require.NoError(t, exportRequest(producer.convertTraces(context.Background(), createTestTraces())))
+
+	assert.Empty(t, mid.received("/metrics"))
+	events := mid.received("/events")
+	require.Len(t, events, 1)
+
+	batch := decodeEventBatch(t, events[0])
+	require.Len(t, batch, 1)
+	event := batch[0]
+	assert.Equal(t, "GET /cart", event.Type)
+	assert.Equal(t, "checkout", event.Resource)
+	assert.Equal(t, "host-1", event.Node)
+	assert.Equal(t, spanErrorSeverity, event.Severity)
+	assert.Equal(t, "connection refused", event.Description)
+	assert.Equal(t, "0102030405060708090a0b0c0d0e0f10", event.AdditionalInfo["trace_id"])
+	assert.Equal(t, "0102030405060708", event.AdditionalInfo["span_id"])
+	assert.Equal(t, "GET", event.AdditionalInfo["http_method"])
+}
+
+func TestConvertTracesREDMetrics(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Traces.SendREDMetrics = true
+	producer := newTestProducer(t, cfg)
+
+	require.NoError(t, exportRequest(producer.convertTraces(context.Background(), createTestTraces())))
+
+	payloads := mid.received("/metrics")
+	require.Len(t, payloads, 1)
+
+	var metrics []ServiceNowMetric
+	require.NoError(t, json.Unmarshal(payloads[0], &metrics))
+	require.Len(t, metrics, 3)
+
+	values := make(map[string]float64)
+	for _, m := range metrics {
+		assert.Equal(t, m.MetricType+";span.name=GET /cart", m.ResourcePath)
+		assert.Equal(t, "host-1", m.Node)
+		values[m.MetricType] = m.Value
+	}
+	assert.Equal(t, map[string]float64{
+		spanRequestsMetricType: 2,
+		spanErrorsMetricType:   1,
+		spanDurationMetricType: 200,
+	}, values)
+}
+
+func TestConvertTracesREDMetricsRates(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Traces.SendREDMetrics = true
+	producer := newTestProducer(t, cfg)
+
+	// The batch covers 10 seconds.
+	td := createTestTraces()
+	spans := td.ResourceSpans().At(0).ScopeSpans().At(0).Spans()
+	late := spans.AppendEmpty()
+	spans.At(0).CopyTo(late)
+	late.SetEndTimestamp(pcommon.NewTimestampFromTime(time.Unix(1700000010, 0)))
+
+	req, err := producer.convertTraces(context.Background(), td)
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, m := range req.(*serviceNowRequest).Metrics {
+		values[m.MetricType] = m.Value
+	}
+	assert.InDelta(t, 0.3, values[spanRequestsMetricType], 1e-9)
+	assert.InDelta(t, 0.1, values[spanErrorsMetricType], 1e-9)
+}
+
+func TestConvertTracesREDMetricsPerService(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.Traces.SendREDMetrics = true
+	cfg.Mapping.ResourcePath = "${service.name}/${default}"
+	producer := newTestProducer(t, cfg)
+
+	// The spans of checkout are split across two scopes and two resources.
+	td := createTestTraces()
+	createTestTraces().ResourceSpans().MoveAndAppendTo(td.ResourceSpans())
+	td.ResourceSpans().At(1).ScopeSpans().At(0).Scope().SetName("other")
+	other := createTestTraces().ResourceSpans().At(0)
+	other.Resource().Attributes().PutStr("service.name", "payment")
+	other.CopyTo(td.ResourceSpans().AppendEmpty())
+
+	require.NoError(t, exportRequest(producer.convertTraces(context.Background(), td)))
+
+	payloads := mid.received("/metrics")
+	require.Len(t, payloads, 1)
+
+	var metrics []ServiceNowMetric
+	require.NoError(t, json.Unmarshal(payloads[0], &metrics))
+	require.Len(t, metrics, 6)
+
+	requests := make(map[string]float64)
+	for _, m := range metrics {
+		if m.MetricType == spanRequestsMetricType {
+			requests[m.ResourcePath] = m.Value
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		"checkout/span.requests;span.name=GET /cart": 4,
+		"payment/span.requests;span.name=GET /cart":  2,
+	}, requests)
+}
+
+func TestExportRetriesOnlyRejectedEvents(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) > 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushEventsURL = server.URL
+	cfg.EventsBatch.MaxSize = 2
+	producer := newTestProducer(t, cfg)
+
+	md := plog.NewLogs()
+	for i := 0; i < 2; i++ {
+		records := md.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+		records.AppendEmpty().Body().SetStr(fmt.Sprintf("log %d", 2*i))
+		records.AppendEmpty().Body().SetStr(fmt.Sprintf("log %d", 2*i+1))
+	}
+	md.ResourceLogs().At(1).ScopeLogs().At(0).LogRecords().AppendEmpty().Body().SetStr("log 4")
+
+	req, err := producer.convertLogs(context.Background(), md)
+	require.NoError(t, err)
+	err = req.Export(context.Background())
+	require.Error(t, err)
+
+	// Only the events after the accepted batch are retried.
+	remaining := req.(exporterhelper.RequestErrorHandler).OnError(err).(*serviceNowRequest)
+	require.Len(t, remaining.Events, 3)
+	assert.Equal(t, "log 2", remaining.Events[0].Description)
+	assert.Equal(t, "log 4", remaining.Events[2].Description)
+}
+
+func TestExportTracesRetriesOnlyMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/metrics" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.PushEventsURL = server.URL + "/events"
+	cfg.PushMetricsURL = server.URL + "/metrics"
+	cfg.Traces.SendREDMetrics = true
+	producer := newTestProducer(t, cfg)
+
+	req, err := producer.convertTraces(context.Background(), createTestTraces())
+	require.NoError(t, err)
+	err = req.Export(context.Background())
+	require.Error(t, err)
+	assert.False(t, consumererror.IsPermanent(err))
+
+	// The error event was sent, only the RED metrics are retried.
+	remaining := req.(exporterhelper.RequestErrorHandler).OnError(err).(*serviceNowRequest)
+	assert.Empty(t, remaining.Events)
+	assert.Len(t, remaining.Metrics, 3)
+}
+
+func TestConvertLogsBindsCI(t *testing.T) {
+	mid := newMidServerMock(t)
+	cfg := mid.config()
+	cfg.PushLogsURL = mid.server.URL + "/logs"
+	producer := newTestProducer(t, cfg)
+
+	md := plog.NewLogs()
+	rl := md.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("host.name", "host-1")
+	rl.Resource().Attributes().PutStr(ciSysIDAttribute, "abc123")
+	rl.ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("hello")
+
+	require.NoError(t, exportRequest(producer.convertLogs(context.Background(), md)))
+
+	payloads := mid.received("/logs")
+	require.Len(t, payloads, 1)
+	var logs []ServiceNowLog
+	require.NoError(t, json.Unmarshal(payloads[0], &logs))
+	require.Len(t, logs, 1)
+	assert.Equal(t, "abc123", logs[0].CiSysId)
+	assert.Nil(t, logs[0].Ci2LogID)
+	assert.Equal(t, "host-1", logs[0].Node)
+}
@@ -0,0 +1,135 @@
+package servicenowexporter
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+// ServiceNow event severities, from 0 (Clear) to 5 (Info).
+const (
+	severityClear    = "0"
+	severityCritical = "1"
+	severityMajor    = "2"
+	severityMinor    = "3"
+	severityWarning  = "4"
+	severityInfo     = "5"
+)
+
+// SeverityMappingConfig overrides how OTel log severities are mapped to ServiceNow severities.
+type SeverityMappingConfig struct {
+	// Ranges maps inclusive ranges of OTel severity numbers to a ServiceNow severity, checked before the defaults
+	Ranges []SeverityRange `mapstructure:"ranges"`
+
+	// Text maps severity text values (case insensitive) to a ServiceNow severity, checked before any range
+	Text map[string]string `mapstructure:"text"`
+
+	// Default is the ServiceNow severity used when nothing else matches
+	Default string `mapstructure:"default"`
+}
+
+// SeverityRange maps the OTel severity numbers between Min and Max (inclusive) to a ServiceNow severity.
+type SeverityRange struct {
+	Min      int32  `mapstructure:"min"`
+	Max      int32  `mapstructure:"max"`
+	Severity string `mapstructure:"severity"`
+}
+
+// defaultSeverityRanges follows the OTel log data model severity ranges.
+var defaultSeverityRanges = []SeverityRange{
+	{Min: int32(plog.SeverityNumberTrace), Max: int32(plog.SeverityNumberInfo4), Severity: severityInfo},
+	{Min: int32(plog.SeverityNumberWarn), Max: int32(plog.SeverityNumberWarn4), Severity: severityWarning},
+	{Min: int32(plog.SeverityNumberError), Max: int32(plog.SeverityNumberError4), Severity: severityMajor},
+	{Min: int32(plog.SeverityNumberFatal), Max: int32(plog.SeverityNumberFatal4), Severity: severityCritical},
+}
+
+// defaultSeverityText is used when a log record has no severity number.
+var defaultSeverityText = map[string]string{
+	"trace":     severityInfo,
+	"debug":     severityInfo,
+	"info":      severityInfo,
+	"notice":    severityInfo,
+	"warn":      severityWarning,
+	"warning":   severityWarning,
+	"err":       severityMajor,
+	"error":     severityMajor,
+	"crit":      severityCritical,
+	"critical":  severityCritical,
+	"alert":     severityCritical,
+	"emerg":     severityCritical,
+	"emergency": severityCritical,
+	"fatal":     severityCritical,
+}
+
+func (cfg *SeverityMappingConfig) Validate() error {
+	for _, r := range cfg.Ranges {
+		if r.Min > r.Max {
+			return fmt.Errorf("severity_mapping: range min %d is greater than max %d", r.Min, r.Max)
+		}
+		if err := validateSeverity(r.Severity); err != nil {
+			return err
+		}
+	}
+	for _, sev := range cfg.Text {
+		if err := validateSeverity(sev); err != nil {
+			return err
+		}
+	}
+	if cfg.Default != "" {
+		return validateSeverity(cfg.Default)
+	}
+	return nil
+}
+
+func validateSeverity(sev string) error {
+	switch sev {
+	case severityClear, severityCritical, severityMajor, severityMinor, severityWarning, severityInfo:
+		return nil
+	}
+	return fmt.Errorf("severity_mapping: invalid ServiceNow severity %q, must be between 0 and 5", sev)
+}
+
+// severityMapper maps OTel log severities to ServiceNow severities.
+type severityMapper struct {
+	ranges      []SeverityRange
+	text        map[string]string
+	defaultText map[string]string
+	defaultSev  string
+}
+
+func newSeverityMapper(cfg SeverityMappingConfig) *severityMapper {
+	m := &severityMapper{
+		ranges:      append(append([]SeverityRange{}, cfg.Ranges...), defaultSeverityRanges...),
+		text:        make(map[string]string, len(cfg.Text)),
+		defaultText: defaultSeverityText,
+		defaultSev:  cfg.Default,
+	}
+	for k, v := range cfg.Text {
+		m.text[strings.ToLower(k)] = v
+	}
+	if m.defaultSev == "" {
+		m.defaultSev = severityInfo
+	}
+	return m
+}
+
+// severity returns the ServiceNow severity for a log record. Configured text values
+// take precedence, then the severity number ranges and finally the default text values.
+func (m *severityMapper) severity(number plog.SeverityNumber, text string) string {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if sev, ok := m.text[text]; ok {
+		return sev
+	}
+	if number != plog.SeverityNumberUnspecified {
+		for _, r := range m.ranges {
+			if int32(number) >= r.Min && int32(number) <= r.Max {
+				return r.Severity
+			}
+		}
+	}
+	if sev, ok := m.defaultText[text]; ok {
+		return sev
+	}
+	return m.defaultSev
+}
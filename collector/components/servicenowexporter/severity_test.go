@@ -0,0 +1,62 @@
+package servicenowexporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestDefaultSeverityMapping(t *testing.T) {
+	m := newSeverityMapper(SeverityMappingConfig{})
+
+	tests := []struct {
+		name   string
+		number plog.SeverityNumber
+		text   string
+		want   string
+	}{
+		{name: "unspecified", want: severityInfo},
+		{name: "debug", number: plog.SeverityNumberDebug, want: severityInfo},
+		{name: "info", number: plog.SeverityNumberInfo2, want: severityInfo},
+		{name: "warn", number: plog.SeverityNumberWarn, want: severityWarning},
+		{name: "error", number: plog.SeverityNumberError3, want: severityMajor},
+		{name: "fatal", number: plog.SeverityNumberFatal4, want: severityCritical},
+		{name: "text only", text: "Critical", want: severityCritical},
+		{name: "number wins over default text", number: plog.SeverityNumberWarn, text: "error", want: severityWarning},
+		{name: "unknown text", text: "verbose", want: severityInfo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, m.severity(tt.number, tt.text))
+		})
+	}
+}
+
+func TestConfiguredSeverityMapping(t *testing.T) {
+	m := newSeverityMapper(SeverityMappingConfig{
+		Ranges: []SeverityRange{
+			{Min: int32(plog.SeverityNumberError), Max: int32(plog.SeverityNumberError2), Severity: severityMinor},
+		},
+		Text:    map[string]string{"AUDIT": severityWarning},
+		Default: severityClear,
+	})
+
+	assert.Equal(t, severityMinor, m.severity(plog.SeverityNumberError2, ""))
+	assert.Equal(t, severityMajor, m.severity(plog.SeverityNumberError3, ""))
+	assert.Equal(t, severityWarning, m.severity(plog.SeverityNumberInfo, "audit"))
+	assert.Equal(t, severityClear, m.severity(plog.SeverityNumberUnspecified, "verbose"))
+}
+
+func TestSeverityMappingValidate(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	assert.NoError(t, component.ValidateConfig(cfg))
+
+	cfg.SeverityMapping.Ranges = []SeverityRange{{Min: 20, Max: 17, Severity: severityMajor}}
+	assert.EqualError(t, component.ValidateConfig(cfg), "severity_mapping: range min 20 is greater than max 17")
+
+	cfg.SeverityMapping.Ranges = nil
+	cfg.SeverityMapping.Text = map[string]string{"error": "7"}
+	assert.EqualError(t, component.ValidateConfig(cfg), `severity_mapping: invalid ServiceNow severity "7", must be between 0 and 5`)
+}